@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -104,10 +105,26 @@ func buildWorker(repoRoot string) (workerDeps, error) {
 		return workerDeps{}, err
 	}
 
-	planner := orchestrator.NewPlanner(repoRoot, cluster, comments, statuses, runs, policy.NewBuiltinEvaluator())
+	planner := orchestrator.NewPlanner(repoRoot, cluster, comments, statuses, runs, policyEvaluator(repoRoot, os.Getenv("THULE_POLICY_DIR")))
+	planner.SetBaseTrees(repo.NewMergeBaseTrees(repoRoot, getEnv("THULE_REPO_BASE_REF", defaultBaseRef)))
+	planner.SetGitOnlyClusters(getEnvList("THULE_GIT_ONLY_CLUSTER_REFS"))
+	planner.SetPolicyExceptions(os.Getenv("THULE_POLICY_EXCEPTIONS"))
 	return workerDeps{jobs: jobs, syncer: syncer, plan: planner.PlanForEvent, mrChangedFile: mrChanges}, nil
 }
 
+// policyEvaluator adds Rego policies from policyDir (relative to the repo
+// root unless absolute) to the builtin rules.
+func policyEvaluator(repoRoot, policyDir string) policy.Evaluator {
+	if policyDir == "" {
+		return policy.NewBuiltinEvaluator()
+	}
+	if !filepath.IsAbs(policyDir) {
+		policyDir = filepath.Join(repoRoot, policyDir)
+	}
+	log.Printf("thule-worker rego policies enabled dir=%s", policyDir)
+	return policy.Evaluators{policy.NewBuiltinEvaluator(), policy.NewRegoEvaluator(policyDir)}
+}
+
 func runWorker(ctx context.Context, jobs queue.Queue, syncer repoSyncer, plan planFunc, mrChangedFiles mrChangedFilesFunc) error {
 	maintenanceEvery := getEnvInt("THULE_REPO_MAINTENANCE_EVERY", defaultRepoMaintenanceEvery)
 	jobsSinceMaintenance := 0
@@ -173,6 +190,16 @@ func runWorker(ctx context.Context, jobs queue.Queue, syncer repoSyncer, plan pl
 	}
 }
 
+func getEnvList(key string) []string {
+	out := []string{}
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func getEnvInt(key string, fallback int) int {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/example/thule/internal/orchestrator"
+	"github.com/example/thule/internal/policy"
 	"github.com/example/thule/internal/queue"
 )
 
@@ -114,6 +115,17 @@ func TestGetEnvInt(t *testing.T) {
 	}
 }
 
+func TestGetEnvList(t *testing.T) {
+	t.Setenv("THULE_WORKER_TEST_LIST", " a, ,b ")
+	if got := getEnvList("THULE_WORKER_TEST_LIST"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("expected trimmed list, got %+v", got)
+	}
+	t.Setenv("THULE_WORKER_TEST_LIST", "")
+	if got := getEnvList("THULE_WORKER_TEST_LIST"); len(got) != 0 {
+		t.Fatalf("expected empty list, got %+v", got)
+	}
+}
+
 func TestBuildWorkerFromEnv(t *testing.T) {
 	t.Setenv("THULE_QUEUE", "memory")
 	t.Setenv("THULE_REPO_URL", "https://example.com/repo.git")
@@ -262,3 +274,16 @@ func TestRunWorkerPeriodicRepoMaintenance(t *testing.T) {
 		t.Fatal("expected worker to exit with context cancellation")
 	}
 }
+
+func TestPolicyEvaluator(t *testing.T) {
+	if _, ok := policyEvaluator("/repo", "").(*policy.BuiltinEvaluator); !ok {
+		t.Fatal("expected only builtin rules without a policy dir")
+	}
+	evaluators, ok := policyEvaluator("/repo", "policies").(policy.Evaluators)
+	if !ok || len(evaluators) != 2 {
+		t.Fatalf("expected builtin and rego evaluators, got %#v", evaluators)
+	}
+	if rego, ok := evaluators[1].(*policy.RegoEvaluator); !ok || rego.Dir != "/repo/policies" {
+		t.Fatalf("expected policy dir relative to the repo root, got %#v", evaluators[1])
+	}
+}
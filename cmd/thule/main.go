@@ -6,12 +6,14 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/example/thule/internal/config"
 	"github.com/example/thule/internal/diff"
 	"github.com/example/thule/internal/policy"
 	"github.com/example/thule/internal/render"
 	"github.com/example/thule/internal/report"
+	"github.com/example/thule/internal/schema"
 )
 
 var exitFunc = os.Exit
@@ -36,6 +38,8 @@ func runPlan(args []string) {
 	fs := flag.NewFlagSet("plan", flag.ExitOnError)
 	project := fs.String("project", ".", "project directory containing thule.conf")
 	sha := fs.String("sha", "local", "commit sha label for report output")
+	policyDir := fs.String("policy-dir", "", "directory of Rego policies to evaluate in addition to the builtin rules")
+	exceptionsPath := fs.String("exceptions", "", "policy exceptions file waiving findings by rule and resource selector")
 	fs.Parse(args)
 
 	cfgPath := filepath.Join(*project, "thule.conf")
@@ -51,12 +55,30 @@ func runPlan(args []string) {
 		exitFunc(1)
 		return
 	}
-	changes, summary := diff.Compute(desired, nil, diff.Options{PruneDeletes: cfg.Diff.Prune, IgnoreFields: cfg.Diff.IgnoreFields})
-	findings := policy.NewBuiltinEvaluator().Evaluate(desired, cfg.Policy.Profile)
+	changes, summary := diff.Compute(desired, nil, diff.Options{PruneDeletes: cfg.Diff.Prune, IgnoreFields: cfg.Diff.IgnoreFields, MergeKeys: cfg.Diff.MergeKeys})
+	evaluator := policy.Evaluators{policy.NewBuiltinEvaluator()}
+	if *policyDir != "" {
+		evaluator = append(evaluator, policy.NewRegoEvaluator(*policyDir))
+	}
+	findings := evaluator.EvaluateChanges(desired, changes, cfg.Policy.Profile)
+	findings = append(findings, policy.CheckUnresolvedSources(desired)...)
+	findings = append(findings, policy.CheckDeprecatedAPIs(desired, cfg.KubeVersion)...)
+	if validator, err := schema.Bundled(cfg.KubeVersion); err == nil {
+		findings = append(findings, validator.WithCRDs(desired).Validate(desired)...)
+	}
+	var exceptions []policy.Exception
+	if *exceptionsPath != "" {
+		if exceptions, err = policy.LoadExceptions(*exceptionsPath); err != nil {
+			fmt.Fprintf(os.Stderr, "load policy exceptions: %v\n", err)
+			exitFunc(1)
+			return
+		}
+	}
+	findings = policy.ApplyWaivers(cfg.Project, findings, desired, exceptions, *exceptionsPath, time.Now())
 	body := report.BuildPlanComment(cfg.Project, *sha, changes, summary, findings, cfg.Comment.MaxResourceDetails)
 	fmt.Println(strings.TrimSpace(body))
 }
 
 func usage() {
-	fmt.Println("thule <command>\n\nCommands:\n  plan --project <path> [--sha <sha>]  Run local plan preview")
+	fmt.Println("thule <command>\n\nCommands:\n  plan --project <path> [--sha <sha>] [--policy-dir <path>] [--exceptions <path>]  Run local plan preview")
 }
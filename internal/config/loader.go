@@ -5,15 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/example/thule/internal/diff"
 	"github.com/example/thule/pkg/thuleconfig"
 )
 
 //go:embed thule.schema.json
 var thuleSchema []byte
 
+// profilePattern matches policy profiles: a builtin profile or the name of a
+// Rego subdirectory of the policy directory, which must not leave it.
+var profilePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
 func Load(path string) (thuleconfig.Config, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -60,6 +66,26 @@ func Validate(cfg thuleconfig.Config) error {
 		return fmt.Errorf("render.path is required")
 	}
 
+	switch cfg.Diff.Mode {
+	case "", thuleconfig.DiffModeLive, thuleconfig.DiffModeGitOnly:
+	default:
+		return fmt.Errorf("unsupported diff.mode %q", cfg.Diff.Mode)
+	}
+	for _, rule := range cfg.Diff.IgnoreFields {
+		if err := diff.ValidateIgnoreField(rule); err != nil {
+			return fmt.Errorf("diff.ignoreFields entry %q: %w", rule, err)
+		}
+	}
+	for _, rule := range cfg.Diff.MergeKeys {
+		path, key, ok := strings.Cut(rule, "=")
+		if !ok || strings.TrimSpace(path) == "" || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("diff.mergeKeys entry %q must be path=key", rule)
+		}
+	}
+	if p := cfg.Policy.Profile; p != "" && !profilePattern.MatchString(p) {
+		return fmt.Errorf("policy.profile %q must be baseline, restricted, strict or the name of a policy subdirectory", p)
+	}
+
 	_ = thuleSchema
 	return nil
 }
@@ -67,8 +93,23 @@ func Validate(cfg thuleconfig.Config) error {
 func decodeSimpleYAML(in string) (thuleconfig.Config, error) {
 	cfg := thuleconfig.Config{}
 	lines := strings.Split(in, "\n")
-	section := ""
-	subsection := ""
+	// Track the enclosing mapping keys so both nested (`helm:` / `valuesFiles:`)
+	// and dotted (`helm.valuesFiles:`) spellings resolve to the same path.
+	type scope struct {
+		indent int
+		key    string
+	}
+	scopes := []scope{}
+	pathAt := func(indent int) string {
+		for len(scopes) > 0 && scopes[len(scopes)-1].indent >= indent {
+			scopes = scopes[:len(scopes)-1]
+		}
+		keys := make([]string, 0, len(scopes))
+		for _, s := range scopes {
+			keys = append(keys, s.key)
+		}
+		return strings.Join(keys, ".")
+	}
 
 	for _, raw := range lines {
 		if strings.TrimSpace(raw) == "" || strings.HasPrefix(strings.TrimSpace(raw), "#") {
@@ -79,11 +120,17 @@ func decodeSimpleYAML(in string) (thuleconfig.Config, error) {
 
 		if strings.HasPrefix(line, "- ") {
 			item := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "- ")), `"'`)
-			switch section + "." + subsection {
+			switch pathAt(indent) {
 			case "diff.ignoreFields":
 				cfg.Diff.IgnoreFields = append(cfg.Diff.IgnoreFields, item)
+			case "diff.mergeKeys":
+				cfg.Diff.MergeKeys = append(cfg.Diff.MergeKeys, item)
+			case "diff.fieldManagers":
+				cfg.Diff.FieldManagers = append(cfg.Diff.FieldManagers, item)
 			case "render.helm.valuesFiles":
 				cfg.Render.Helm.ValuesFiles = append(cfg.Render.Helm.ValuesFiles, item)
+			case "render.helm.apiVersions":
+				cfg.Render.Helm.APIVersions = append(cfg.Render.Helm.APIVersions, item)
 			case "render.flux.includeKinds":
 				cfg.Render.Flux.IncludeKinds = append(cfg.Render.Flux.IncludeKinds, item)
 			}
@@ -92,13 +139,8 @@ func decodeSimpleYAML(in string) (thuleconfig.Config, error) {
 
 		if strings.HasSuffix(line, ":") {
 			key := strings.TrimSuffix(line, ":")
-			switch indent {
-			case 0:
-				section = key
-				subsection = ""
-			case 2:
-				subsection = key
-			}
+			pathAt(indent)
+			scopes = append(scopes, scope{indent: indent, key: key})
 			continue
 		}
 
@@ -108,42 +150,36 @@ func decodeSimpleYAML(in string) (thuleconfig.Config, error) {
 		}
 		k := strings.TrimSpace(parts[0])
 		v := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		if prefix := pathAt(indent); prefix != "" {
+			k = prefix + "." + k
+		}
 
-		switch section {
-		case "":
-			switch k {
-			case "version":
-				cfg.Version = v
-			case "project":
-				cfg.Project = v
-			case "clusterRef":
-				cfg.ClusterRef = v
-			case "namespace":
-				cfg.Namespace = v
-			}
-		case "render":
-			switch subsection {
-			case "":
-				switch k {
-				case "mode":
-					cfg.Render.Mode = v
-				case "path":
-					cfg.Render.Path = v
-				}
-			case "helm":
-				if k == "releaseName" {
-					cfg.Render.Helm.ReleaseName = v
-				}
-			}
-		case "diff":
-			if k == "prune" {
-				cfg.Diff.Prune = (v == "true")
-			}
-		case "comment":
-			if k == "maxResourceDetails" {
-				if iv, err := strconv.Atoi(v); err == nil {
-					cfg.Comment.MaxResourceDetails = iv
-				}
+		switch k {
+		case "version":
+			cfg.Version = v
+		case "project":
+			cfg.Project = v
+		case "clusterRef":
+			cfg.ClusterRef = v
+		case "namespace":
+			cfg.Namespace = v
+		case "kubeVersion":
+			cfg.KubeVersion = v
+		case "render.mode":
+			cfg.Render.Mode = v
+		case "render.path":
+			cfg.Render.Path = v
+		case "render.helm.releaseName":
+			cfg.Render.Helm.ReleaseName = v
+		case "diff.prune":
+			cfg.Diff.Prune = (v == "true")
+		case "diff.mode":
+			cfg.Diff.Mode = v
+		case "policy.profile":
+			cfg.Policy.Profile = v
+		case "comment.maxResourceDetails":
+			if iv, err := strconv.Atoi(v); err == nil {
+				cfg.Comment.MaxResourceDetails = iv
 			}
 		}
 	}
@@ -7,12 +7,12 @@ import (
 )
 
 func TestDecodeAndValidateAcceptsYAML(t *testing.T) {
-	input := []byte("version: v1\nproject: payments\nclusterRef: prod-eu-1\nnamespace: payments\nrender:\n  mode: kustomize\n  path: .\ndiff:\n  prune: true\n  ignoreFields:\n    - metadata.annotations\ncomment:\n  maxResourceDetails: 25\n")
+	input := []byte("version: v1\nproject: payments\nclusterRef: prod-eu-1\nnamespace: payments\nrender:\n  mode: kustomize\n  path: .\ndiff:\n  prune: true\n  mode: git-only\n  ignoreFields:\n    - metadata.annotations\n  mergeKeys:\n    - spec.listeners=name\n  fieldManagers:\n    - kustomize-controller\ncomment:\n  maxResourceDetails: 25\n")
 	cfg, err := Decode(input)
 	if err != nil {
 		t.Fatalf("decode failed: %v", err)
 	}
-	if !cfg.Diff.Prune || len(cfg.Diff.IgnoreFields) != 1 || cfg.Comment.MaxResourceDetails != 25 {
+	if !cfg.Diff.Prune || cfg.Diff.Mode != "git-only" || len(cfg.Diff.IgnoreFields) != 1 || len(cfg.Diff.MergeKeys) != 1 || len(cfg.Diff.FieldManagers) != 1 || cfg.Comment.MaxResourceDetails != 25 {
 		t.Fatalf("expected parsed phase2 fields: %+v", cfg)
 	}
 	if err := Validate(cfg); err != nil {
@@ -21,7 +21,7 @@ func TestDecodeAndValidateAcceptsYAML(t *testing.T) {
 }
 
 func TestValidateBytesRejectsInvalidConfigs(t *testing.T) {
-	tests := []string{"version", "version: v1\nproject: p\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: unknown\n  path: .\n"}
+	tests := []string{"version", "version: v1\nproject: p\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: unknown\n  path: .\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: yaml\n  path: .\ndiff:\n  mode: offline\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: yaml\n  path: .\ndiff:\n  mergeKeys:\n    - spec.listeners\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: yaml\n  path: .\ndiff:\n  ignoreFields:\n    - 'Deployment:metadata.annotations[\"unterminated'\n", "version: v1\nproject: p\nclusterRef: c\nnamespace: n\nrender:\n  mode: yaml\n  path: .\npolicy:\n  profile: ../shared\n"}
 	for _, tc := range tests {
 		if err := ValidateBytes([]byte(tc)); err == nil {
 			t.Fatal("expected validation error")
@@ -76,3 +76,20 @@ func TestDecodeRejectsInvalidPayload(t *testing.T) {
 		t.Fatal("expected error for invalid payload")
 	}
 }
+
+func TestDecodeSimpleYAMLNestedSections(t *testing.T) {
+	input := []byte("version: v1\nproject: p\nclusterRef: c\nnamespace: n\nkubeVersion: v1.29.0\nrender:\n  mode: helm\n  path: chart\n  helm:\n    releaseName: web\n    valuesFiles:\n      - values/base.yaml\n      - values/prod.yaml\n    apiVersions:\n      - monitoring.coreos.com/v1\n  flux:\n    includeKinds:\n      - ConfigMap\npolicy:\n  profile: strict\n")
+	cfg, err := Decode(input)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if cfg.KubeVersion != "v1.29.0" || cfg.Render.Helm.ReleaseName != "web" || cfg.Policy.Profile != "strict" {
+		t.Fatalf("unexpected scalar fields: %+v", cfg)
+	}
+	if len(cfg.Render.Helm.ValuesFiles) != 2 || cfg.Render.Helm.ValuesFiles[1] != "values/prod.yaml" {
+		t.Fatalf("unexpected values files: %+v", cfg.Render.Helm.ValuesFiles)
+	}
+	if len(cfg.Render.Helm.APIVersions) != 1 || len(cfg.Render.Flux.IncludeKinds) != 1 {
+		t.Fatalf("unexpected nested lists: %+v", cfg.Render)
+	}
+}
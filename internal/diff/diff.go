@@ -17,6 +17,10 @@ const (
 	Patch  Action = "PATCH"
 	Delete Action = "DELETE"
 	NoOp   Action = "NO-OP"
+	// Rename and Move replace a DELETE and CREATE of near-identical objects
+	// whose name (Rename) or namespace (Move) changed.
+	Rename Action = "RENAME"
+	Move   Action = "MOVE"
 )
 
 type Change struct {
@@ -26,8 +30,26 @@ type Change struct {
 	ChangedPaths  []string
 	AttributeDiff []string
 	Risks         []string
-	CurrentYAML   string
-	DesiredYAML   string
+	// Immutable explains changes to immutable fields; set together with the
+	// recreate-required risk.
+	Immutable []string
+	// Images lists container image changes of workloads and HelmReleases.
+	Images      []ImageChange
+	CurrentYAML string
+	DesiredYAML string
+	// Current and Desired are the normalized live and desired bodies, nil on
+	// the side the object does not exist. Unlike CurrentYAML, Current keeps
+	// live-only fields.
+	Current map[string]any
+	Desired map[string]any
+	// Parent is the Flux HelmRelease or Kustomization the resource was
+	// rendered from, if any.
+	Parent string
+	// PreviousID is the identity a renamed or moved resource had before.
+	PreviousID string
+	// Origin and PathOrigins are only set by ComputeThreeWay.
+	Origin      Origin
+	PathOrigins map[string]Origin
 }
 
 type Summary struct {
@@ -35,6 +57,7 @@ type Summary struct {
 	Patches int
 	Deletes int
 	NoOps   int
+	Renames int
 }
 
 type Options struct {
@@ -43,16 +66,34 @@ type Options struct {
 	// IgnoreActualExtraFields drops fields that only exist in live resources
 	// (e.g. API-server defaulted/computed attributes) before comparison.
 	IgnoreActualExtraFields bool
+	// MergeKeys adds "path=key" rules matching list elements by key (e.g.
+	// "spec.listeners=name" for a CRD) on top of the built-in core types.
+	MergeKeys []string
+	// FieldManagers limits the comparison to fields the live object's
+	// managedFields assign to these managers (e.g. "kustomize-controller"),
+	// plus fields the desired manifest introduces. Empty compares everything.
+	FieldManagers []string
 }
 
 func Compute(desired, actual []render.Resource, opts Options) ([]Change, Summary) {
+	return compute(desired, actual, nil, opts)
+}
+
+func compute(desired, actual []render.Resource, base map[string]render.Resource, opts Options) ([]Change, Summary) {
 	dm := map[string]render.Resource{}
 	am := map[string]render.Resource{}
+	owned := map[string]map[string]any{}
+	ignore := newIgnoreRules(opts.IgnoreFields)
 	for _, r := range desired {
-		dm[r.ID()] = normalize(r, opts.IgnoreFields)
+		dm[r.ID()] = normalize(r, ignore)
 	}
 	for _, r := range actual {
-		am[r.ID()] = normalize(r, opts.IgnoreFields)
+		if len(opts.FieldManagers) > 0 {
+			if fields, ok := ownedFields(r.Body, opts.FieldManagers); ok {
+				owned[r.ID()] = fields
+			}
+		}
+		am[r.ID()] = normalize(r, ignore)
 	}
 
 	keys := map[string]struct{}{}
@@ -68,21 +109,34 @@ func Compute(desired, actual []render.Resource, opts Options) ([]Change, Summary
 	}
 	sort.Strings(sorted)
 
+	lists := newMergeKeys(opts.MergeKeys)
 	changes := make([]Change, 0, len(sorted))
 	summary := Summary{}
 	for _, k := range sorted {
 		d, dok := dm[k]
 		a, aok := am[k]
-		change := Change{ID: k}
+		change := Change{ID: k, Parent: d.Parent}
+		if !dok {
+			change.Parent = a.Parent
+		}
 		switch {
 		case dok && !aok:
 			change.Action = Create
+			change.Desired = d.Body
 			change.DesiredYAML = mustYAML(d.Body)
+			change.Images = imageChanges(d.Kind, d.Body, nil)
+			if base != nil {
+				change.Origin = existenceOrigin(base, k, true)
+			}
 			summary.Creates++
 		case !dok && aok:
 			if opts.PruneDeletes {
 				change.Action = Delete
+				change.Current = a.Body
 				change.CurrentYAML = mustYAML(a.Body)
+				if base != nil {
+					change.Origin = existenceOrigin(base, k, false)
+				}
 				summary.Deletes++
 			} else {
 				continue
@@ -90,33 +144,51 @@ func Compute(desired, actual []render.Resource, opts Options) ([]Change, Summary
 		case dok && aok:
 			desiredBody := d.Body
 			actualBody := a.Body
+			if fields, ok := owned[k]; ok {
+				pruneUnowned(desiredBody, actualBody, fields)
+			}
+			change.Current, change.Desired = actualBody, desiredBody
 			if opts.IgnoreActualExtraFields {
-				if projected, ok := projectActualToDesired(desiredBody, actualBody).(map[string]any); ok {
+				if projected, ok := lists.projectActualToDesired("", desiredBody, actualBody).(map[string]any); ok {
 					actualBody = projected
 				}
 			}
-			if equal(desiredBody, actualBody) {
+			compared := lists.alignSpellings(d, desiredBody, actualBody)
+			paths := lists.changedFieldPaths(desiredBody, compared)
+			if len(paths) == 0 {
 				change.Action = NoOp
 				summary.NoOps++
 				changes = append(changes, change)
 				continue
 			}
 			change.Action = Patch
-			change.ChangedKeys = changedTopLevelKeys(desiredBody, actualBody)
-			change.ChangedPaths = changedFieldPaths(desiredBody, actualBody)
-			change.AttributeDiff = buildAttributeDiffLines(desiredBody, actualBody)
+			change.ChangedKeys = topLevelKeys(paths)
+			change.ChangedPaths = paths
+			change.AttributeDiff = lists.buildAttributeDiffLines(desiredBody, compared)
 			change.Risks = detectRisks(d, a, change.ChangedKeys)
+			if immutable := immutableChanges(d.Kind, desiredBody, compared); len(immutable) > 0 {
+				change.Immutable = immutable
+				change.Risks = append(change.Risks, RiskRecreateRequired)
+			}
+			change.Images = imageChanges(d.Kind, desiredBody, actualBody)
 			change.CurrentYAML = mustYAML(actualBody)
 			change.DesiredYAML = mustYAML(desiredBody)
+			if base != nil {
+				change.PathOrigins = lists.classifyPaths(desiredBody, lists.alignSpellings(d, desiredBody, base[k].Body), compared)
+				change.Origin = combineOrigins(change.PathOrigins)
+			}
 			summary.Patches++
 		}
 		changes = append(changes, change)
 	}
+	if opts.PruneDeletes {
+		changes, summary = lists.pairRenames(changes, summary, dm, am, opts)
+	}
 
 	return changes, summary
 }
 
-func normalize(r render.Resource, ignore []string) render.Resource {
+func normalize(r render.Resource, ignore ignoreRules) render.Resource {
 	cp := deepCopyMap(r.Body)
 	if cleaned, ok := pruneNilValues(cp).(map[string]any); ok {
 		cp = cleaned
@@ -154,42 +226,25 @@ func normalize(r render.Resource, ignore []string) render.Resource {
 			cp["spec"] = spec
 		}
 	}
-	for _, p := range ignore {
-		deletePath(cp, p)
-	}
+	ignore.apply(r, cp)
 	r.Body = cp
 	return r
 }
 
-func deletePath(obj map[string]any, path string) {
-	parts := strings.Split(path, ".")
-	if len(parts) == 0 {
-		return
-	}
-	cur := obj
-	for i := 0; i < len(parts)-1; i++ {
-		next, ok := cur[parts[i]].(map[string]any)
-		if !ok {
-			return
-		}
-		cur = next
-	}
-	delete(cur, parts[len(parts)-1])
-}
-
-func changedTopLevelKeys(a, b map[string]any) []string {
-	keys := map[string]struct{}{}
-	for k := range a {
-		keys[k] = struct{}{}
-	}
-	for k := range b {
-		keys[k] = struct{}{}
-	}
+// topLevelKeys returns the top-level fields touched by changed paths.
+func topLevelKeys(paths []string) []string {
+	seen := map[string]struct{}{}
 	out := []string{}
-	for k := range keys {
-		if !equalAny(a[k], b[k]) {
-			out = append(out, k)
+	for _, p := range paths {
+		top := p
+		if i := strings.IndexAny(p, ".["); i >= 0 {
+			top = p[:i]
+		}
+		if _, ok := seen[top]; ok {
+			continue
 		}
+		seen[top] = struct{}{}
+		out = append(out, top)
 	}
 	sort.Strings(out)
 	return out
@@ -225,12 +280,6 @@ func equalAny(a, b any) bool {
 	return string(ab) == string(bb)
 }
 
-func equal(a, b map[string]any) bool {
-	ab, _ := json.Marshal(a)
-	bb, _ := json.Marshal(b)
-	return string(ab) == string(bb)
-}
-
 func deepCopyMap(in map[string]any) map[string]any {
 	if in == nil {
 		return nil
@@ -241,7 +290,7 @@ func deepCopyMap(in map[string]any) map[string]any {
 	return out
 }
 
-func changedFieldPaths(desired, actual any) []string {
+func (m mergeKeys) changedFieldPaths(desired, actual any) []string {
 	seen := map[string]struct{}{}
 	var walk func(path string, d, a any)
 	walk = func(path string, d, a any) {
@@ -272,6 +321,16 @@ func changedFieldPaths(desired, actual any) []string {
 			}
 			return
 		}
+		ds, dok := d.([]any)
+		as, aok := a.([]any)
+		if dok && aok {
+			if _, pairs, ok := m.match(path, ds, as); ok {
+				for _, p := range pairs {
+					walk(path+p.segment, p.d, p.a)
+				}
+				return
+			}
+		}
 		if path == "" {
 			path = "<root>"
 		}
@@ -302,7 +361,7 @@ func mustYAML(obj any) (out string) {
 	return string(b)
 }
 
-func projectActualToDesired(desired, actual any) any {
+func (m mergeKeys) projectActualToDesired(path string, desired, actual any) any {
 	switch dv := desired.(type) {
 	case map[string]any:
 		av, ok := actual.(map[string]any)
@@ -315,7 +374,11 @@ func projectActualToDesired(desired, actual any) any {
 			if !exists {
 				continue
 			}
-			out[k] = projectActualToDesired(dvv, avv)
+			next := k
+			if path != "" {
+				next = path + "." + k
+			}
+			out[k] = m.projectActualToDesired(next, dvv, avv)
 		}
 		return out
 	case []any:
@@ -324,12 +387,22 @@ func projectActualToDesired(desired, actual any) any {
 			return actual
 		}
 		out := make([]any, 0, len(dv))
+		if _, pairs, ok := m.match(path, dv, av); ok {
+			// Live-only elements (e.g. defaulted ports) are dropped; missing
+			// ones stay missing so they diff as additions.
+			for _, p := range pairs {
+				if p.dOK && p.aOK {
+					out = append(out, m.projectActualToDesired(path+p.segment, p.d, p.a))
+				}
+			}
+			return out
+		}
 		for i := range dv {
 			if i >= len(av) {
 				out = append(out, nil)
 				continue
 			}
-			out = append(out, projectActualToDesired(dv[i], av[i]))
+			out = append(out, m.projectActualToDesired(fmt.Sprintf("%s[%d]", path, i), dv[i], av[i]))
 		}
 		return out
 	default:
@@ -360,7 +433,7 @@ func pruneNilValues(in any) any {
 	}
 }
 
-func buildAttributeDiffLines(desired, actual any) []string {
+func (m mergeKeys) buildAttributeDiffLines(desired, actual any) []string {
 	lines := []string{}
 	var walk func(path string, d any, dOK bool, a any, aOK bool)
 	walk = func(path string, d any, dOK bool, a any, aOK bool) {
@@ -396,6 +469,12 @@ func buildAttributeDiffLines(desired, actual any) []string {
 			ds, dok := d.([]any)
 			as, aok := a.([]any)
 			if dok && aok {
+				if _, pairs, ok := m.match(path, ds, as); ok {
+					for _, p := range pairs {
+						walk(displayPath(path)+p.segment, p.d, p.dOK, p.a, p.aOK)
+					}
+					return
+				}
 				if len(ds) != len(as) {
 					lines = append(lines, fmt.Sprintf("- %s: %s", displayPath(path), formatValue(a)))
 					lines = append(lines, fmt.Sprintf("+ %s: %s", displayPath(path), formatValue(d)))
@@ -242,7 +242,7 @@ func TestHelpersAndRiskDetection(t *testing.T) {
 }
 
 func TestProjectActualToDesiredAndPruneNilValues(t *testing.T) {
-	projected := projectActualToDesired(
+	projected := mergeKeys(nil).projectActualToDesired("",
 		map[string]any{
 			"spec": map[string]any{
 				"ports": []any{
@@ -269,11 +269,11 @@ func TestProjectActualToDesiredAndPruneNilValues(t *testing.T) {
 	}
 
 	// When desired/actual types differ, actual value is preserved.
-	if got := projectActualToDesired([]any{1}, "raw-string"); got != "raw-string" {
+	if got := mergeKeys(nil).projectActualToDesired("", []any{1}, "raw-string"); got != "raw-string" {
 		t.Fatalf("expected mismatched type passthrough, got %#v", got)
 	}
 	// Missing actual array entries produce nil placeholders.
-	arr := projectActualToDesired([]any{"a", "b"}, []any{"a"}).([]any)
+	arr := mergeKeys(nil).projectActualToDesired("", []any{"a", "b"}, []any{"a"}).([]any)
 	if len(arr) != 2 || arr[1] != nil {
 		t.Fatalf("expected nil placeholder for missing entry, got %#v", arr)
 	}
@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/example/thule/internal/render"
+)
+
+// ignoreRule is a parsed diff.ignoreFields entry:
+//
+//	[[<group>/]<Kind>[/<name>]:]<path>
+//
+// Kind and name may be "*" (name also accepts glob patterns); an omitted
+// group matches every group and "core" selects the core group. With two
+// selector parts, a first part starting with an upper-case letter or "*" is
+// a Kind ("Deployment/web"), otherwise a group ("apps/Deployment"). Paths are
+// dotted with JSONPath-style brackets: "[*]" matches every list element or
+// map key, "[0]" a list index, and ["a.b/c"] a key containing dots.
+type ignoreRule struct {
+	group    string
+	hasGroup bool
+	kind     string
+	name     string
+	path     []pathSegment
+}
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+type ignoreRules []ignoreRule
+
+// ValidateIgnoreField reports whether rule is a well-formed ignoreFields
+// entry.
+func ValidateIgnoreField(rule string) error {
+	_, err := parseIgnoreRule(rule)
+	return err
+}
+
+// newIgnoreRules parses ignoreFields entries. Malformed rules are ignored;
+// config validation rejects them earlier.
+func newIgnoreRules(rules []string) ignoreRules {
+	out := make(ignoreRules, 0, len(rules))
+	for _, raw := range rules {
+		if rule, err := parseIgnoreRule(raw); err == nil {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
+
+func parseIgnoreRule(raw string) (ignoreRule, error) {
+	raw = strings.TrimSpace(raw)
+	rule := ignoreRule{kind: "*", name: "*"}
+	if i := strings.Index(raw, ":"); i >= 0 && !strings.ContainsAny(raw[:i], `["'`) {
+		selector := strings.Split(raw[:i], "/")
+		raw = raw[i+1:]
+		switch len(selector) {
+		case 1:
+			rule.kind = selector[0]
+		case 2:
+			if isKindSelector(selector[0]) {
+				rule.kind, rule.name = selector[0], selector[1]
+			} else {
+				rule.group, rule.kind, rule.hasGroup = selector[0], selector[1], true
+			}
+		case 3:
+			rule.group, rule.kind, rule.name, rule.hasGroup = selector[0], selector[1], selector[2], true
+		default:
+			return ignoreRule{}, fmt.Errorf("ignore rule selector %q must be [group/]Kind[/name]", strings.Join(selector, "/"))
+		}
+		if rule.group == "core" {
+			rule.group = ""
+		}
+		if rule.kind == "" || rule.name == "" {
+			return ignoreRule{}, fmt.Errorf("ignore rule selector %q has an empty kind or name", strings.Join(selector, "/"))
+		}
+		if _, err := path.Match(rule.name, ""); err != nil {
+			return ignoreRule{}, fmt.Errorf("ignore rule name pattern %q: %w", rule.name, err)
+		}
+	}
+	segments, err := parsePathSegments(raw)
+	if err != nil {
+		return ignoreRule{}, err
+	}
+	rule.path = segments
+	return rule, nil
+}
+
+// isKindSelector tells Kinds, which are UpperCamelCase, from API groups,
+// which are lower-case DNS names.
+func isKindSelector(part string) bool {
+	return part == "*" || (part != "" && part[0] >= 'A' && part[0] <= 'Z')
+}
+
+func parsePathSegments(raw string) ([]pathSegment, error) {
+	out := []pathSegment{}
+	for i := 0; i < len(raw); {
+		switch raw[i] {
+		case '.':
+			i++
+		case '[':
+			end, seg, err := parseBracket(raw, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, seg)
+			i = end
+		default:
+			j := i
+			for j < len(raw) && raw[j] != '.' && raw[j] != '[' {
+				j++
+			}
+			key := raw[i:j]
+			out = append(out, pathSegment{key: key, wildcard: key == "*"})
+			i = j
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("ignore rule has an empty path")
+	}
+	return out, nil
+}
+
+// parseBracket parses the bracket starting at raw[start] and returns the
+// offset just past it.
+func parseBracket(raw string, start int) (int, pathSegment, error) {
+	body := raw[start+1:]
+	if body != "" && (body[0] == '"' || body[0] == '\'') {
+		quote := body[0]
+		closing := strings.IndexByte(body[1:], quote)
+		if closing < 0 || len(body) < closing+3 || body[closing+2] != ']' {
+			return 0, pathSegment{}, fmt.Errorf("unterminated bracket in ignore path %q", raw)
+		}
+		return start + closing + 4, pathSegment{key: body[1 : closing+1]}, nil
+	}
+	closing := strings.IndexByte(body, ']')
+	if closing <= 0 {
+		return 0, pathSegment{}, fmt.Errorf("unterminated or empty bracket in ignore path %q", raw)
+	}
+	inner := body[:closing]
+	seg := pathSegment{key: inner}
+	switch {
+	case inner == "*":
+		seg.wildcard = true
+	default:
+		if n, err := strconv.Atoi(inner); err == nil {
+			seg = pathSegment{index: n, isIndex: true}
+		}
+	}
+	return start + closing + 2, seg, nil
+}
+
+func (r ignoreRule) matches(res render.Resource) bool {
+	if r.kind != "*" && r.kind != res.Kind {
+		return false
+	}
+	if r.hasGroup && r.group != "*" {
+		group := ""
+		if i := strings.Index(res.APIVersion, "/"); i >= 0 {
+			group = res.APIVersion[:i]
+		}
+		if r.group != group {
+			return false
+		}
+	}
+	ok, _ := path.Match(r.name, res.Name)
+	return ok
+}
+
+// apply removes the fields the rules select from body.
+func (rules ignoreRules) apply(res render.Resource, body map[string]any) {
+	for _, rule := range rules {
+		if rule.matches(res) {
+			deleteSegments(body, rule.path)
+		}
+	}
+}
+
+// deleteSegments removes what segs select below node and returns node, which
+// is a new slice when list elements were removed.
+func deleteSegments(node any, segs []pathSegment) any {
+	seg, last := segs[0], len(segs) == 1
+	switch n := node.(type) {
+	case map[string]any:
+		switch {
+		case seg.isIndex:
+		case seg.wildcard && last:
+			for k := range n {
+				delete(n, k)
+			}
+		case seg.wildcard:
+			for k, v := range n {
+				n[k] = deleteSegments(v, segs[1:])
+			}
+		case last:
+			delete(n, seg.key)
+		default:
+			if v, ok := n[seg.key]; ok {
+				n[seg.key] = deleteSegments(v, segs[1:])
+			}
+		}
+	case []any:
+		switch {
+		case seg.wildcard && last:
+			return []any{}
+		case seg.wildcard:
+			for i, v := range n {
+				n[i] = deleteSegments(v, segs[1:])
+			}
+		case seg.isIndex && seg.index < len(n) && last:
+			return append(append([]any{}, n[:seg.index]...), n[seg.index+1:]...)
+		case seg.isIndex && seg.index < len(n):
+			n[seg.index] = deleteSegments(n[seg.index], segs[1:])
+		}
+	}
+	return node
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestComputeAppliesScopedIgnoreRules(t *testing.T) {
+	workload := func(kind, name string, replicas float64, restartedAt string) render.Resource {
+		return render.Resource{APIVersion: "apps/v1", Kind: kind, Namespace: "n", Name: name, Body: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]any{"namespace": "n", "name": name},
+			"spec": map[string]any{
+				"replicas": replicas,
+				"template": map[string]any{
+					"metadata": map[string]any{"annotations": map[string]any{"kubectl.kubernetes.io/restartedAt": restartedAt}},
+					"spec": map[string]any{"containers": []any{
+						map[string]any{"name": "a", "env": []any{map[string]any{"name": "BUILD", "value": restartedAt}}},
+						map[string]any{"name": "b", "env": []any{map[string]any{"name": "BUILD", "value": restartedAt}}},
+					}},
+				},
+			},
+		}}
+	}
+	desired := []render.Resource{workload("Deployment", "web", 2, "t1"), workload("StatefulSet", "db", 2, "t1")}
+	actual := []render.Resource{workload("Deployment", "web", 5, "t2"), workload("StatefulSet", "db", 5, "t2")}
+	opts := Options{IgnoreFields: []string{
+		"apps/Deployment:spec.replicas",
+		`*:spec.template.metadata.annotations["kubectl.kubernetes.io/restartedAt"]`,
+		"spec.template.spec.containers[*].env",
+	}}
+	changes, summary := Compute(desired, actual, opts)
+	if summary.NoOps != 1 || summary.Patches != 1 {
+		t.Fatalf("expected only the statefulset replicas to differ, got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.Action == Patch && (c.ID != "apps/v1|StatefulSet|n|db" || len(c.ChangedPaths) != 1 || c.ChangedPaths[0] != "spec.replicas") {
+			t.Fatalf("unexpected patch %+v", c)
+		}
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	rule, err := parseIgnoreRule(`networking.k8s.io/Ingress/web-*:metadata.annotations['a.b/c'].x[0]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.group != "networking.k8s.io" || rule.kind != "Ingress" || rule.name != "web-*" || len(rule.path) != 5 {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	if rule.path[2].key != "a.b/c" || !rule.path[4].isIndex || rule.path[4].index != 0 {
+		t.Fatalf("unexpected path %+v", rule.path)
+	}
+	if !rule.matches(render.Resource{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "web-1"}) || rule.matches(render.Resource{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "web-1"}) {
+		t.Fatal("unexpected selector matching")
+	}
+	named, _ := parseIgnoreRule("Deployment/web:spec.replicas")
+	if named.hasGroup || named.kind != "Deployment" || named.name != "web" {
+		t.Fatalf("expected Kind/name selector, got %+v", named)
+	}
+	if !named.matches(render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}) || named.matches(render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"}) {
+		t.Fatal("unexpected Kind/name matching")
+	}
+	grouped, _ := parseIgnoreRule("apps/Deployment:spec.replicas")
+	if grouped.group != "apps" || grouped.kind != "Deployment" || grouped.name != "*" {
+		t.Fatalf("expected group/Kind selector, got %+v", grouped)
+	}
+	core, _ := parseIgnoreRule("core/Service:spec.clusterIP")
+	if !core.matches(render.Resource{APIVersion: "v1", Kind: "Service"}) {
+		t.Fatal("expected core group to match v1")
+	}
+
+	list := map[string]any{"items": []any{"a", "b", "c"}}
+	deleteSegments(list, mustParsePath(t, "items[1]"))
+	if got := list["items"].([]any); len(got) != 2 || got[1] != "c" {
+		t.Fatalf("expected index removal, got %v", got)
+	}
+
+	for _, bad := range []string{"", `metadata.annotations["x`, "a/b/c/d:spec", "Kind/[:spec"} {
+		if err := ValidateIgnoreField(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func mustParsePath(t *testing.T, raw string) []pathSegment {
+	t.Helper()
+	segs, err := parsePathSegments(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segs
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/thule/internal/render"
+)
+
+// ImageRef is a container image reference split into its parts.
+type ImageRef struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func (r ImageRef) String() string {
+	out := r.Repository
+	if r.Tag != "" {
+		out += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		out += "@" + r.Digest
+	}
+	return out
+}
+
+// ImageChange is a container (or HelmRelease values path) whose image
+// differs between live and desired state. Old is empty for new containers and
+// New for removed ones.
+type ImageChange struct {
+	Container string
+	Old       ImageRef
+	New       ImageRef
+}
+
+// ParseImageRef splits "registry/repo:tag@sha256:..." into its parts.
+func ParseImageRef(image string) ImageRef {
+	ref := ImageRef{}
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, ref.Digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+	ref.Repository = image
+	return ref
+}
+
+// imageChanges compares the images of a workload's containers, or the image
+// values of a HelmRelease, between actual and desired bodies. Either body may
+// be nil, for creates and deletes.
+func imageChanges(kind string, desired, actual map[string]any) []ImageChange {
+	var d, a map[string]ImageRef
+	switch {
+	case kind == "HelmRelease":
+		d, a = map[string]ImageRef{}, map[string]ImageRef{}
+		dv, _ := lookupPath(desired, "spec.values")
+		av, _ := lookupPath(actual, "spec.values")
+		collectValueImages("values", dv, d)
+		collectValueImages("values", av, a)
+	default:
+		d = containerImages(render.Resource{Kind: kind, Body: desired})
+		a = containerImages(render.Resource{Kind: kind, Body: actual})
+		if len(d) == 0 && len(a) == 0 {
+			return nil
+		}
+	}
+	names := map[string]struct{}{}
+	for n := range d {
+		names[n] = struct{}{}
+	}
+	for n := range a {
+		names[n] = struct{}{}
+	}
+	out := []ImageChange{}
+	for n := range names {
+		if d[n] != a[n] {
+			out = append(out, ImageChange{Container: n, Old: a[n], New: d[n]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+func containerImages(r render.Resource) map[string]ImageRef {
+	out := map[string]ImageRef{}
+	podSpec, _ := r.PodSpec()
+	for _, field := range []string{"initContainers", "containers"} {
+		items, _ := podSpec[field].([]any)
+		for _, item := range items {
+			c, _ := item.(map[string]any)
+			name, _ := c["name"].(string)
+			image, _ := c["image"].(string)
+			if image == "" {
+				continue
+			}
+			if field == "initContainers" {
+				name += " (init)"
+			}
+			out[name] = ParseImageRef(image)
+		}
+	}
+	return out
+}
+
+// collectValueImages finds Helm values following the common "image" shapes:
+// a string reference or a map of repository/tag/digest (plus registry).
+func collectValueImages(path string, v any, out map[string]ImageRef) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vv := range t {
+			next := path + "." + k
+			if k == "image" {
+				if ref, ok := valuesImageRef(vv); ok {
+					out[next] = ref
+					continue
+				}
+			}
+			collectValueImages(next, vv, out)
+		}
+	case []any:
+		for i, vv := range t {
+			collectValueImages(fmt.Sprintf("%s[%d]", path, i), vv, out)
+		}
+	}
+}
+
+func valuesImageRef(v any) (ImageRef, bool) {
+	switch t := v.(type) {
+	case string:
+		return ParseImageRef(t), t != ""
+	case map[string]any:
+		repo, _ := t["repository"].(string)
+		if repo == "" {
+			return ImageRef{}, false
+		}
+		if registry, _ := t["registry"].(string); registry != "" {
+			repo = registry + "/" + repo
+		}
+		ref := ImageRef{Repository: repo}
+		ref.Tag = scalarString(t["tag"])
+		ref.Digest = scalarString(t["digest"])
+		return ref, true
+	}
+	return ImageRef{}, false
+}
+
+func scalarString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestParseImageRef(t *testing.T) {
+	tests := map[string]ImageRef{
+		"nginx":                            {Repository: "nginx"},
+		"registry:5000/team/app:1.2":       {Repository: "registry:5000/team/app", Tag: "1.2"},
+		"ghcr.io/app:v2@sha256:abc":        {Repository: "ghcr.io/app", Tag: "v2", Digest: "sha256:abc"},
+		"registry:5000/team/app@sha256:ff": {Repository: "registry:5000/team/app", Digest: "sha256:ff"},
+	}
+	for in, want := range tests {
+		if got := ParseImageRef(in); got != want {
+			t.Fatalf("%s: expected %+v, got %+v", in, want, got)
+		}
+		if got := ParseImageRef(in).String(); got != in {
+			t.Fatalf("expected %s to round-trip, got %s", in, got)
+		}
+	}
+}
+
+func TestComputeExtractsImageChanges(t *testing.T) {
+	cronJob := func(image, sidecar string) render.Resource {
+		return render.Resource{APIVersion: "batch/v1", Kind: "CronJob", Namespace: "n", Name: "report", Body: map[string]any{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"metadata":   map[string]any{"namespace": "n", "name": "report"},
+			"spec": map[string]any{"jobTemplate": map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+				"initContainers": []any{map[string]any{"name": "migrate", "image": image}},
+				"containers":     []any{map[string]any{"name": "report", "image": image}, map[string]any{"name": "sidecar", "image": sidecar}},
+			}}}}},
+		}}
+	}
+	changes, _ := Compute([]render.Resource{cronJob("app:2", "proxy:1")}, []render.Resource{cronJob("app:1", "proxy:1")}, Options{})
+	images := changes[0].Images
+	if len(images) != 2 || images[0].Container != "migrate (init)" || images[1].Container != "report" || images[1].Old.Tag != "1" || images[1].New.Tag != "2" {
+		t.Fatalf("unexpected image changes %+v", images)
+	}
+	changes, _ = Compute([]render.Resource{cronJob("app:2", "proxy:1")}, nil, Options{})
+	images = changes[0].Images
+	if changes[0].Action != Create || len(images) != 3 || images[2].Container != "sidecar" || images[2].Old != (ImageRef{}) || images[2].New.String() != "proxy:1" {
+		t.Fatalf("expected the images of a created workload, got %+v", images)
+	}
+
+	release := func(tag any) render.Resource {
+		return render.Resource{APIVersion: "helm.toolkit.fluxcd.io/v2", Kind: "HelmRelease", Namespace: "n", Name: "web", Body: map[string]any{
+			"apiVersion": "helm.toolkit.fluxcd.io/v2",
+			"kind":       "HelmRelease",
+			"metadata":   map[string]any{"namespace": "n", "name": "web"},
+			"spec": map[string]any{"values": map[string]any{
+				"image":  map[string]any{"registry": "ghcr.io", "repository": "org/web", "tag": tag},
+				"worker": map[string]any{"image": "ghcr.io/org/worker:1"},
+			}},
+		}}
+	}
+	changes, _ = Compute([]render.Resource{release("1.1")}, []render.Resource{release("1.0")}, Options{})
+	images = changes[0].Images
+	if len(images) != 1 || images[0].Container != "values.image" || images[0].Old.String() != "ghcr.io/org/web:1.0" || images[0].New.String() != "ghcr.io/org/web:1.1" {
+		t.Fatalf("unexpected helm image changes %+v", images)
+	}
+}
@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// RiskRecreateRequired tags patches touching immutable fields: the API server
+// rejects them, so Flux fails to apply unless it deletes and recreates the
+// object (force).
+const RiskRecreateRequired = "recreate-required"
+
+type immutableRule struct {
+	kinds []string
+	check func(desired, actual map[string]any) []string
+}
+
+var immutableRules = []immutableRule{
+	{kinds: []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet"}, check: immutablePaths("spec.selector")},
+	{kinds: []string{"StatefulSet"}, check: immutablePaths("spec.volumeClaimTemplates", "spec.serviceName", "spec.podManagementPolicy")},
+	{kinds: []string{"Job"}, check: immutablePaths("spec.selector", "spec.template", "spec.completionMode")},
+	{kinds: []string{"Service"}, check: serviceImmutableChanges},
+	{kinds: []string{"PersistentVolumeClaim"}, check: pvcImmutableChanges},
+	{kinds: []string{"ConfigMap", "Secret"}, check: immutableDataChanges},
+}
+
+// immutableChanges explains every change between desired and actual the API
+// server will refuse to apply in place.
+func immutableChanges(kind string, desired, actual map[string]any) []string {
+	out := []string{}
+	for _, rule := range immutableRules {
+		if contains(rule.kinds, kind) {
+			out = append(out, rule.check(desired, actual)...)
+		}
+	}
+	return out
+}
+
+func immutablePaths(paths ...string) func(desired, actual map[string]any) []string {
+	return func(desired, actual map[string]any) []string {
+		out := []string{}
+		for _, p := range paths {
+			d, dok := lookupPath(desired, p)
+			a, aok := lookupPath(actual, p)
+			if (dok || aok) && !equalAny(d, a) {
+				out = append(out, fmt.Sprintf("`%s` is immutable", p))
+			}
+		}
+		return out
+	}
+}
+
+func serviceImmutableChanges(desired, actual map[string]any) []string {
+	out := []string{}
+	for _, p := range []string{"spec.clusterIP", "spec.clusterIPs"} {
+		d, dok := lookupPath(desired, p)
+		a, aok := lookupPath(actual, p)
+		if dok && aok && !equalAny(d, a) && !isEmptyValue(a) {
+			out = append(out, fmt.Sprintf("`%s` is immutable once allocated", p))
+		}
+	}
+	dt, _ := lookupPath(desired, "spec.type")
+	at, _ := lookupPath(actual, "spec.type")
+	from, to := serviceType(at), serviceType(dt)
+	if from != to && (from == "ExternalName" || to == "ExternalName") {
+		out = append(out, fmt.Sprintf("`spec.type` change from %s to %s reallocates the cluster IP", from, to))
+	}
+	return out
+}
+
+func serviceType(v any) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return "ClusterIP"
+}
+
+func pvcImmutableChanges(desired, actual map[string]any) []string {
+	out := immutablePaths("spec.storageClassName", "spec.volumeName", "spec.volumeMode", "spec.accessModes")(desired, actual)
+	d, dok := lookupPath(desired, "spec.resources.requests.storage")
+	a, aok := lookupPath(actual, "spec.resources.requests.storage")
+	if dok && aok {
+		dq, derr := resource.ParseQuantity(fmt.Sprint(d))
+		aq, aerr := resource.ParseQuantity(fmt.Sprint(a))
+		if derr == nil && aerr == nil && dq.Cmp(aq) < 0 {
+			out = append(out, fmt.Sprintf("`spec.resources.requests.storage` cannot shrink (%s to %s)", aq.String(), dq.String()))
+		}
+	}
+	return out
+}
+
+func immutableDataChanges(desired, actual map[string]any) []string {
+	if locked, _ := actual["immutable"].(bool); !locked {
+		return nil
+	}
+	out := []string{}
+	if stillLocked, _ := desired["immutable"].(bool); !stillLocked {
+		out = append(out, "`immutable` cannot be unset once true")
+	}
+	return append(out, immutablePaths("data", "binaryData")(desired, actual)...)
+}
+
+func lookupPath(obj map[string]any, path string) (any, bool) {
+	var cur any = obj
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func isEmptyValue(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []any:
+		return len(t) == 0
+	}
+	return false
+}
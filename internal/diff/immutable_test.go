@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestComputeFlagsImmutableFieldChanges(t *testing.T) {
+	deployment := func(app string) render.Resource {
+		return render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"namespace": "n", "name": "web"},
+			"spec":       map[string]any{"selector": map[string]any{"matchLabels": map[string]any{"app": app}}},
+		}}
+	}
+	changes, _ := Compute([]render.Resource{deployment("web-v2")}, []render.Resource{deployment("web")}, Options{})
+	if !contains(changes[0].Risks, RiskRecreateRequired) || len(changes[0].Immutable) != 1 || !strings.Contains(changes[0].Immutable[0], "spec.selector") {
+		t.Fatalf("expected selector change to require recreate, got %+v", changes[0])
+	}
+}
+
+func TestImmutableChangesByKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		desired map[string]any
+		actual  map[string]any
+		want    string
+	}{
+		{
+			name:    "pvc shrink",
+			kind:    "PersistentVolumeClaim",
+			desired: map[string]any{"spec": map[string]any{"resources": map[string]any{"requests": map[string]any{"storage": "5Gi"}}}},
+			actual:  map[string]any{"spec": map[string]any{"resources": map[string]any{"requests": map[string]any{"storage": "10Gi"}}}},
+			want:    "cannot shrink",
+		},
+		{
+			name:    "pvc storage class",
+			kind:    "PersistentVolumeClaim",
+			desired: map[string]any{"spec": map[string]any{"storageClassName": "fast"}},
+			actual:  map[string]any{"spec": map[string]any{"storageClassName": "standard"}},
+			want:    "spec.storageClassName",
+		},
+		{
+			name:    "service cluster ip",
+			kind:    "Service",
+			desired: map[string]any{"spec": map[string]any{"clusterIP": "10.0.0.2"}},
+			actual:  map[string]any{"spec": map[string]any{"clusterIP": "10.0.0.1"}},
+			want:    "spec.clusterIP",
+		},
+		{
+			name:    "service type",
+			kind:    "Service",
+			desired: map[string]any{"spec": map[string]any{"type": "ExternalName"}},
+			actual:  map[string]any{"spec": map[string]any{}},
+			want:    "from ClusterIP to ExternalName",
+		},
+		{
+			name:    "immutable configmap",
+			kind:    "ConfigMap",
+			desired: map[string]any{"immutable": true, "data": map[string]any{"a": "2"}},
+			actual:  map[string]any{"immutable": true, "data": map[string]any{"a": "1"}},
+			want:    "`data` is immutable",
+		},
+		{
+			name:    "job template",
+			kind:    "Job",
+			desired: map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"restartPolicy": "Never"}}}},
+			actual:  map[string]any{"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"restartPolicy": "OnFailure"}}}},
+			want:    "spec.template",
+		},
+	}
+	for _, tt := range tests {
+		got := immutableChanges(tt.kind, tt.desired, tt.actual)
+		if len(got) != 1 || !strings.Contains(got[0], tt.want) {
+			t.Fatalf("%s: expected %q, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if got := immutableChanges("PersistentVolumeClaim",
+		map[string]any{"spec": map[string]any{"resources": map[string]any{"requests": map[string]any{"storage": "20Gi"}}}},
+		map[string]any{"spec": map[string]any{"resources": map[string]any{"requests": map[string]any{"storage": "10Gi"}}}}); len(got) != 0 {
+		t.Fatalf("expected pvc expansion to be allowed, got %v", got)
+	}
+	if got := immutableChanges("ConfigMap", map[string]any{"data": map[string]any{"a": "2"}}, map[string]any{"data": map[string]any{"a": "1"}}); len(got) != 0 {
+		t.Fatalf("expected mutable configmap to be allowed, got %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// builtinMergeKeys maps list field names of core Kubernetes types to their
+// strategic-merge keys. Candidates are tried in order; the first one present
+// and unique on every element is used.
+var builtinMergeKeys = []mergeKeyRule{
+	{path: "containers", keys: []string{"name"}},
+	{path: "initContainers", keys: []string{"name"}},
+	{path: "ephemeralContainers", keys: []string{"name"}},
+	{path: "env", keys: []string{"name"}},
+	{path: "volumes", keys: []string{"name"}},
+	{path: "volumeMounts", keys: []string{"mountPath"}},
+	{path: "volumeDevices", keys: []string{"devicePath"}},
+	{path: "ports", keys: []string{"containerPort", "port"}},
+	{path: "imagePullSecrets", keys: []string{"name"}},
+	{path: "hostAliases", keys: []string{"ip"}},
+	{path: "topologySpreadConstraints", keys: []string{"topologyKey"}},
+	{path: "readinessGates", keys: []string{"conditionType"}},
+	{path: "conditions", keys: []string{"type"}},
+	{path: "webhooks", keys: []string{"name"}},
+}
+
+var listSegmentPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+type mergeKeyRule struct {
+	path string
+	keys []string
+}
+
+// mergeKeys matches list elements by key instead of index. Rules apply when
+// their path equals, or is a dotted suffix of, the list path with element
+// selectors removed; custom rules take precedence over the built-in table.
+type mergeKeys []mergeKeyRule
+
+// newMergeKeys parses "path=key" rules (e.g. "spec.listeners=name") ahead of
+// the built-in table. Malformed rules are ignored; config validation rejects
+// them earlier.
+func newMergeKeys(custom []string) mergeKeys {
+	out := make(mergeKeys, 0, len(custom)+len(builtinMergeKeys))
+	for _, c := range custom {
+		path, key, ok := strings.Cut(c, "=")
+		path, key = strings.TrimSpace(path), strings.TrimSpace(key)
+		if !ok || path == "" || key == "" {
+			continue
+		}
+		out = append(out, mergeKeyRule{path: path, keys: []string{key}})
+	}
+	return append(out, builtinMergeKeys...)
+}
+
+type listPair struct {
+	segment  string
+	d, a     any
+	dOK, aOK bool
+}
+
+// match pairs the elements of the desired and actual lists at path by merge
+// key, in desired order followed by actual-only elements. ok is false when no
+// merge key applies and the lists should be compared by index.
+func (m mergeKeys) match(path string, desired, actual []any) (key string, pairs []listPair, ok bool) {
+	key = m.keyFor(path, desired, actual)
+	if key == "" {
+		return "", nil, false
+	}
+	actualBySegment := map[string]any{}
+	for _, a := range actual {
+		actualBySegment[elementSegment(key, a)] = a
+	}
+	seen := map[string]struct{}{}
+	for _, d := range desired {
+		seg := elementSegment(key, d)
+		a, aOK := actualBySegment[seg]
+		pairs = append(pairs, listPair{segment: seg, d: d, dOK: true, a: a, aOK: aOK})
+		seen[seg] = struct{}{}
+	}
+	for _, a := range actual {
+		seg := elementSegment(key, a)
+		if _, ok := seen[seg]; ok {
+			continue
+		}
+		pairs = append(pairs, listPair{segment: seg, a: a, aOK: true})
+	}
+	return key, pairs, true
+}
+
+func (m mergeKeys) keyFor(path string, lists ...[]any) string {
+	bare := listSegmentPattern.ReplaceAllString(path, "")
+	for _, rule := range m {
+		if bare != rule.path && !strings.HasSuffix(bare, "."+rule.path) {
+			continue
+		}
+		for _, key := range rule.keys {
+			if keyApplies(key, lists...) {
+				return key
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// keyApplies reports whether every element is an object with a scalar value
+// for key, unique within its list.
+func keyApplies(key string, lists ...[]any) bool {
+	for _, list := range lists {
+		seen := map[string]struct{}{}
+		for _, item := range list {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				return false
+			}
+			switch obj[key].(type) {
+			case string, float64, int, int64, bool:
+			default:
+				return false
+			}
+			seg := elementSegment(key, item)
+			if _, dup := seen[seg]; dup {
+				return false
+			}
+			seen[seg] = struct{}{}
+		}
+	}
+	return true
+}
+
+func elementSegment(key string, item any) string {
+	obj, _ := item.(map[string]any)
+	return fmt.Sprintf("[%s=%v]", key, obj[key])
+}
+
+// elementsBySegment indexes list by merge key for lookups from other
+// revisions of the same list.
+func elementsBySegment(key string, list any) map[string]any {
+	out := map[string]any{}
+	items, _ := list.([]any)
+	for _, item := range items {
+		if obj, ok := item.(map[string]any); ok {
+			if _, ok := obj[key]; ok {
+				out[elementSegment(key, item)] = item
+			}
+		}
+	}
+	return out
+}
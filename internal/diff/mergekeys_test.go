@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func deployment(containers ...any) render.Resource {
+	return render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"namespace": "n", "name": "web"},
+		"spec":       map[string]any{"template": map[string]any{"spec": map[string]any{"containers": containers}}},
+	}}
+}
+
+func container(name, image string, env ...any) map[string]any {
+	c := map[string]any{"name": name, "image": image, "ports": []any{map[string]any{"containerPort": 8080}}}
+	if len(env) > 0 {
+		c["env"] = env
+	}
+	return c
+}
+
+func envVar(name, value string) map[string]any {
+	return map[string]any{"name": name, "value": value}
+}
+
+func TestComputeMatchesListsByMergeKey(t *testing.T) {
+	desired := deployment(container("app", "app:1", envVar("A", "1"), envVar("B", "2")), container("sidecar", "proxy:1"))
+	reordered := deployment(container("sidecar", "proxy:1"), container("app", "app:1", envVar("B", "2"), envVar("A", "1")))
+	changes, summary := Compute([]render.Resource{desired}, []render.Resource{reordered}, Options{})
+	if summary.NoOps != 1 {
+		t.Fatalf("expected reordering to be a no-op, got %+v", changes)
+	}
+
+	defaulted := deployment(container("sidecar", "proxy:1"), container("app", "app:1", envVar("A", "1"), envVar("B", "2"), envVar("INJECTED", "x")))
+	live := defaulted.Body["spec"].(map[string]any)["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)[1].(map[string]any)
+	live["ports"] = []any{map[string]any{"containerPort": 8080, "protocol": "TCP"}, map[string]any{"containerPort": 9090}}
+	_, summary = Compute([]render.Resource{desired}, []render.Resource{defaulted}, Options{IgnoreActualExtraFields: true})
+	if summary.NoOps != 1 {
+		t.Fatalf("expected live-only list entries to be ignored, got %+v", summary)
+	}
+
+	changed := deployment(container("app", "app:2", envVar("A", "1"), envVar("C", "3")), container("sidecar", "proxy:1"))
+	changes, _ = Compute([]render.Resource{changed}, []render.Resource{reordered}, Options{})
+	if len(changes) != 1 || changes[0].Action != Patch {
+		t.Fatalf("expected patch, got %+v", changes)
+	}
+	got := strings.Join(changes[0].AttributeDiff, "\n")
+	for _, want := range []string{
+		`- spec.template.spec.containers[name=app].image: "app:1"`,
+		`+ spec.template.spec.containers[name=app].image: "app:2"`,
+		`+ spec.template.spec.containers[name=app].env[name=C]: {"name":"C","value":"3"}`,
+		`- spec.template.spec.containers[name=app].env[name=B]: {"name":"B","value":"2"}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in attribute diff:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "sidecar") {
+		t.Fatalf("did not expect unchanged container in attribute diff:\n%s", got)
+	}
+	wantPaths := []string{
+		"spec.template.spec.containers[name=app].env[name=B]",
+		"spec.template.spec.containers[name=app].env[name=C]",
+		"spec.template.spec.containers[name=app].image",
+	}
+	if strings.Join(changes[0].ChangedPaths, ",") != strings.Join(wantPaths, ",") {
+		t.Fatalf("unexpected changed paths: %+v", changes[0].ChangedPaths)
+	}
+	if len(changes[0].ChangedKeys) != 1 || changes[0].ChangedKeys[0] != "spec" {
+		t.Fatalf("unexpected changed keys: %+v", changes[0].ChangedKeys)
+	}
+}
+
+func TestComputeCustomMergeKeys(t *testing.T) {
+	gateway := func(listeners ...any) render.Resource {
+		return render.Resource{APIVersion: "gateway.networking.k8s.io/v1", Kind: "Gateway", Namespace: "n", Name: "gw", Body: map[string]any{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "Gateway",
+			"metadata":   map[string]any{"namespace": "n", "name": "gw"},
+			"spec":       map[string]any{"listeners": listeners},
+		}}
+	}
+	http := map[string]any{"name": "http", "port": 80}
+	https := map[string]any{"name": "https", "port": 443}
+	desired := []render.Resource{gateway(http, https)}
+	actual := []render.Resource{gateway(https, http)}
+	if _, summary := Compute(desired, actual, Options{}); summary.Patches != 1 {
+		t.Fatalf("expected index comparison without a rule, got %+v", summary)
+	}
+	if _, summary := Compute(desired, actual, Options{MergeKeys: []string{"spec.listeners=name", "broken"}}); summary.NoOps != 1 {
+		t.Fatalf("expected custom merge key to match listeners, got %+v", summary)
+	}
+}
+
+func TestMergeKeysFallBackToIndex(t *testing.T) {
+	m := newMergeKeys(nil)
+	dup := []any{map[string]any{"name": "a"}, map[string]any{"name": "a"}}
+	if key := m.keyFor("spec.containers", dup); key != "" {
+		t.Fatalf("expected duplicate keys to fall back to index, got %q", key)
+	}
+	if key := m.keyFor("spec.containers", []any{"a"}); key != "" {
+		t.Fatalf("expected scalar list to fall back to index, got %q", key)
+	}
+	if key := m.keyFor("spec.ports", []any{map[string]any{"port": 80}}); key != "port" {
+		t.Fatalf("expected service ports keyed by port, got %q", key)
+	}
+	if key := m.keyFor("spec.containers[name=a].ports", []any{map[string]any{"containerPort": 80}}); key != "containerPort" {
+		t.Fatalf("expected container ports keyed by containerPort, got %q", key)
+	}
+}
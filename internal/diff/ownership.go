@@ -0,0 +1,213 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ownedFields merges the managedFields (fieldsV1) of the given managers on a
+// live object into one field set. ok is false when the object carries no
+// managedFields, in which case ownership cannot be judged.
+func ownedFields(actual map[string]any, managers []string) (map[string]any, bool) {
+	meta, _ := actual["metadata"].(map[string]any)
+	entries, _ := meta["managedFields"].([]any)
+	if len(entries) == 0 {
+		return nil, false
+	}
+	wanted := map[string]struct{}{}
+	for _, m := range managers {
+		wanted[m] = struct{}{}
+	}
+	owned := map[string]any{}
+	for _, raw := range entries {
+		entry, _ := raw.(map[string]any)
+		manager, _ := entry["manager"].(string)
+		if _, ok := wanted[manager]; !ok {
+			continue
+		}
+		if fields, ok := entry["fieldsV1"].(map[string]any); ok {
+			mergeFieldSets(owned, fields)
+		}
+	}
+	return owned, true
+}
+
+func mergeFieldSets(dst, src map[string]any) {
+	for k, v := range src {
+		sv, _ := v.(map[string]any)
+		dv, ok := dst[k].(map[string]any)
+		if !ok {
+			dv = map[string]any{}
+			dst[k] = dv
+		}
+		mergeFieldSets(dv, sv)
+	}
+}
+
+// pruneUnowned drops fields from the comparison that live state has but the
+// owned field set does not cover: they belong to other managers (HPAs,
+// admission webhooks, ...). Fields only the desired manifest sets are new and
+// stay. Resource identity is always kept.
+func pruneUnowned(desired, actual, owned map[string]any) {
+	fmeta, _ := owned["f:metadata"].(map[string]any)
+	if dm, am := childMaps(desired, actual, "metadata"); am != nil {
+		pruneUnownedMap(dm, am, fmeta, map[string]bool{"name": true, "namespace": true})
+	}
+	pruneUnownedMap(desired, actual, owned, map[string]bool{"apiVersion": true, "kind": true, "metadata": true})
+}
+
+func childMaps(desired, actual map[string]any, key string) (map[string]any, map[string]any) {
+	dm, _ := desired[key].(map[string]any)
+	am, _ := actual[key].(map[string]any)
+	if dm == nil {
+		dm = map[string]any{}
+	}
+	return dm, am
+}
+
+func pruneUnownedMap(desired, actual, owned map[string]any, keep map[string]bool) {
+	for k, av := range actual {
+		if keep[k] {
+			continue
+		}
+		child, ok := owned["f:"+k].(map[string]any)
+		if !ok {
+			delete(actual, k)
+			delete(desired, k)
+			continue
+		}
+		if len(child) == 0 {
+			// The whole value is owned.
+			continue
+		}
+		switch av := av.(type) {
+		case map[string]any:
+			dm, _ := desired[k].(map[string]any)
+			if dm == nil {
+				dm = map[string]any{}
+			}
+			pruneUnownedMap(dm, av, child, nil)
+			if _, ok := desired[k]; !ok && len(av) == 0 {
+				delete(actual, k)
+			}
+		case []any:
+			dl, _ := desired[k].([]any)
+			newD, newA := pruneUnownedList(dl, av, child)
+			actual[k] = newA
+			if _, ok := desired[k]; ok {
+				desired[k] = newD
+			}
+		}
+	}
+}
+
+// pruneUnownedList handles associative ("k:{...}") and set ("v:...") list
+// entries. Unowned live elements are dropped together with the desired
+// element sharing their key.
+func pruneUnownedList(desired, actual []any, owned map[string]any) ([]any, []any) {
+	type keyed struct {
+		fields map[string]any
+		child  map[string]any
+	}
+	keys := []keyed{}
+	values := []any{}
+	for entry, child := range owned {
+		switch {
+		case strings.HasPrefix(entry, "k:"):
+			fields := map[string]any{}
+			if json.Unmarshal([]byte(strings.TrimPrefix(entry, "k:")), &fields) == nil {
+				c, _ := child.(map[string]any)
+				keys = append(keys, keyed{fields: fields, child: c})
+			}
+		case strings.HasPrefix(entry, "v:"):
+			var v any
+			if json.Unmarshal([]byte(strings.TrimPrefix(entry, "v:")), &v) == nil {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(keys) == 0 && len(values) == 0 {
+		return desired, actual
+	}
+	matches := func(item any, fields map[string]any) bool {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		for f, v := range fields {
+			if !equalAny(obj[f], v) {
+				return false
+			}
+		}
+		return true
+	}
+	dropped := map[int]bool{}
+	keptActual := make([]any, 0, len(actual))
+	for _, a := range actual {
+		owner := -1
+		for i, k := range keys {
+			if matches(a, k.fields) {
+				owner = i
+				break
+			}
+		}
+		if owner < 0 {
+			ownedValue := false
+			for _, v := range values {
+				if equalAny(a, v) {
+					ownedValue = true
+					break
+				}
+			}
+			if ownedValue {
+				keptActual = append(keptActual, a)
+				continue
+			}
+			// Drop the desired twin of an unowned element, identified by the
+			// key fields the list uses elsewhere.
+			for i, d := range desired {
+				if len(keys) > 0 && matches(d, pick(a, keys[0].fields)) {
+					dropped[i] = true
+				} else if len(keys) == 0 && equalAny(d, a) {
+					dropped[i] = true
+				}
+			}
+			continue
+		}
+		if len(keys[owner].child) > 0 {
+			if am, ok := a.(map[string]any); ok {
+				for _, d := range desired {
+					if dm, ok := d.(map[string]any); ok && matches(dm, keys[owner].fields) {
+						pruneUnownedMap(dm, am, keys[owner].child, keyFieldSet(keys[owner].fields))
+					}
+				}
+			}
+		}
+		keptActual = append(keptActual, a)
+	}
+	keptDesired := make([]any, 0, len(desired))
+	for i, d := range desired {
+		if !dropped[i] {
+			keptDesired = append(keptDesired, d)
+		}
+	}
+	return keptDesired, keptActual
+}
+
+// pick returns the values of item for the field names of template.
+func pick(item any, template map[string]any) map[string]any {
+	obj, _ := item.(map[string]any)
+	out := map[string]any{}
+	for f := range template {
+		out[f] = obj[f]
+	}
+	return out
+}
+
+func keyFieldSet(fields map[string]any) map[string]bool {
+	out := map[string]bool{}
+	for f := range fields {
+		out[f] = true
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestComputeComparesOnlyManagerOwnedFields(t *testing.T) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(`{
+		"f:metadata": {"f:labels": {"f:app": {}}},
+		"f:spec": {
+			"f:replicas": {},
+			"f:template": {"f:spec": {"f:containers": {
+				"k:{\"name\":\"web\"}": {".": {}, "f:name": {}, "f:image": {}}
+			}}}
+		}
+	}`), &fields); err != nil {
+		t.Fatal(err)
+	}
+	desired := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"namespace": "n", "name": "web", "labels": map[string]any{"app": "web"}},
+		"spec": map[string]any{
+			"replicas":             2.0,
+			"minReadySeconds":      5.0,
+			"revisionHistoryLimit": 3.0,
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "web:2"},
+				map[string]any{"name": "istio-proxy", "image": "proxy:1"},
+			}}},
+		},
+	}}
+	actual := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]any{
+			"namespace":   "n",
+			"name":        "web",
+			"labels":      map[string]any{"app": "web"},
+			"annotations": map[string]any{"cert-manager.io/issuer": "ca"},
+			"managedFields": []any{
+				map[string]any{"manager": "kustomize-controller", "operation": "Apply", "fieldsV1": fields},
+				map[string]any{"manager": "hpa", "operation": "Update", "fieldsV1": map[string]any{"f:spec": map[string]any{"f:replicas": map[string]any{}}}},
+			},
+		},
+		"spec": map[string]any{
+			"replicas":             2.0,
+			"revisionHistoryLimit": 10.0,
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "web:1"},
+				map[string]any{"name": "istio-proxy", "image": "proxy:0"},
+			}}},
+		},
+	}}
+
+	changes, summary := Compute([]render.Resource{desired}, []render.Resource{actual}, Options{FieldManagers: []string{"kustomize-controller"}})
+	if summary.Patches != 1 {
+		t.Fatalf("expected one patch, got %+v", changes)
+	}
+	got := map[string]bool{}
+	for _, p := range changes[0].ChangedPaths {
+		got[p] = true
+	}
+	want := []string{"spec.minReadySeconds", "spec.template.spec.containers[name=web].image"}
+	if len(got) != len(want) {
+		t.Fatalf("expected paths %v, got %v", want, changes[0].ChangedPaths)
+	}
+	for _, p := range want {
+		if !got[p] {
+			t.Fatalf("expected paths %v, got %v", want, changes[0].ChangedPaths)
+		}
+	}
+
+	// Without managedFields there is nothing to judge ownership by.
+	delete(actual.Body["metadata"].(map[string]any), "managedFields")
+	changes, _ = Compute([]render.Resource{desired}, []render.Resource{actual}, Options{FieldManagers: []string{"kustomize-controller"}})
+	if len(changes[0].ChangedPaths) <= len(want) {
+		t.Fatalf("expected full comparison without managedFields, got %v", changes[0].ChangedPaths)
+	}
+}
@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/thule/internal/render"
+)
+
+// renameSimilarity is the share of identical leaf values (identity aside) a
+// deleted and a created resource need to be reported as one rename or move.
+const renameSimilarity = 0.8
+
+// renameMinLeaves keeps tiny bodies (e.g. one-key ConfigMaps) from pairing up
+// on too little evidence.
+const renameMinLeaves = 3
+
+// renameRisks tags what a rename or move does beyond replacing the object:
+// the old one is deleted and a new one created under the new identity.
+var renameRisks = map[string]string{
+	"PersistentVolumeClaim": "pvc-data-loss",
+	"PersistentVolume":      "pv-rebind",
+	"Service":               "service-endpoint-change",
+	"StatefulSet":           "statefulset-pvc-orphaned",
+	"Namespace":             "namespace-content-loss",
+}
+
+// pairRenames replaces DELETE/CREATE pairs of the same apiVersion and kind
+// whose bodies are near-identical with a single RENAME or MOVE change.
+func (m mergeKeys) pairRenames(changes []Change, summary Summary, dm, am map[string]render.Resource, opts Options) ([]Change, Summary) {
+	type candidate struct {
+		create, delete int
+		score          float64
+	}
+	candidates := []candidate{}
+	for ci, c := range changes {
+		if c.Action != Create {
+			continue
+		}
+		d := dm[c.ID]
+		for di, del := range changes {
+			if del.Action != Delete {
+				continue
+			}
+			a := am[del.ID]
+			if a.APIVersion != d.APIVersion || a.Kind != d.Kind {
+				continue
+			}
+			if score := m.similarity(d.Body, m.alignSpellings(d, d.Body, a.Body), opts); score >= renameSimilarity {
+				candidates = append(candidates, candidate{create: ci, delete: di, score: score})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return changes, summary
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	used := map[int]bool{}
+	for _, cand := range candidates {
+		if used[cand.create] || used[cand.delete] {
+			continue
+		}
+		used[cand.create], used[cand.delete] = true, true
+		created, deleted := changes[cand.create], changes[cand.delete]
+		d, a := dm[created.ID], am[deleted.ID]
+		actualBody := a.Body
+		if opts.IgnoreActualExtraFields {
+			if projected, ok := m.projectActualToDesired("", d.Body, actualBody).(map[string]any); ok {
+				actualBody = projected
+			}
+		}
+		renamed := Change{ID: created.ID, PreviousID: deleted.ID, Action: Rename, Parent: created.Parent}
+		if d.Namespace != a.Namespace {
+			renamed.Action = Move
+		}
+		compared := m.alignSpellings(d, d.Body, actualBody)
+		paths := m.changedFieldPaths(d.Body, compared)
+		renamed.ChangedKeys = topLevelKeys(paths)
+		renamed.ChangedPaths = paths
+		renamed.AttributeDiff = m.buildAttributeDiffLines(d.Body, compared)
+		renamed.Risks = detectRisks(d, a, renamed.ChangedKeys)
+		if risk, ok := renameRisks[d.Kind]; ok {
+			renamed.Risks = append(renamed.Risks, risk)
+		}
+		renamed.Images = imageChanges(d.Kind, d.Body, actualBody)
+		renamed.Current, renamed.Desired = a.Body, d.Body
+		renamed.CurrentYAML = mustYAML(actualBody)
+		renamed.DesiredYAML = mustYAML(d.Body)
+		if created.Origin != "" || deleted.Origin != "" {
+			renamed.Origin = OriginDrift
+			if created.Origin == OriginMR || deleted.Origin == OriginMR {
+				renamed.Origin = OriginMR
+			}
+		}
+		changes[cand.create] = renamed
+		summary.Creates--
+		summary.Deletes--
+		summary.Renames++
+	}
+
+	out := make([]Change, 0, len(changes))
+	for i, c := range changes {
+		if c.Action == Delete && used[i] {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, summary
+}
+
+// similarity compares leaf values of both bodies with their identity
+// (apiVersion, kind, name, namespace) removed.
+func (m mergeKeys) similarity(desired, actual map[string]any, opts Options) float64 {
+	if opts.IgnoreActualExtraFields {
+		if projected, ok := m.projectActualToDesired("", desired, actual).(map[string]any); ok {
+			actual = projected
+		}
+	}
+	dl, al := map[string]string{}, map[string]string{}
+	flattenLeaves("", withoutIdentity(desired), dl)
+	flattenLeaves("", withoutIdentity(actual), al)
+	total := len(dl)
+	if len(al) > total {
+		total = len(al)
+	}
+	if total < renameMinLeaves {
+		return 0
+	}
+	same := 0
+	for p, v := range dl {
+		if al[p] == v {
+			same++
+		}
+	}
+	return float64(same) / float64(total)
+}
+
+func withoutIdentity(body map[string]any) map[string]any {
+	cp := deepCopyMap(body)
+	delete(cp, "apiVersion")
+	delete(cp, "kind")
+	if meta, ok := cp["metadata"].(map[string]any); ok {
+		delete(meta, "name")
+		delete(meta, "namespace")
+	}
+	return cp
+}
+
+func flattenLeaves(path string, v any, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vv := range t {
+			next := k
+			if path != "" {
+				next = path + "." + k
+			}
+			flattenLeaves(next, vv, out)
+		}
+	case []any:
+		for i, vv := range t {
+			flattenLeaves(fmt.Sprintf("%s[%d]", path, i), vv, out)
+		}
+	default:
+		b, _ := json.Marshal(t)
+		out[strings.TrimPrefix(path, ".")] = string(b)
+	}
+}
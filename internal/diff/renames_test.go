@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestComputePairsRenamesAndMoves(t *testing.T) {
+	pvc := func(ns, name string) render.Resource {
+		return render.Resource{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: ns, Name: name, Body: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolumeClaim",
+			"metadata":   map[string]any{"namespace": ns, "name": name, "labels": map[string]any{"app": "db"}},
+			"spec": map[string]any{
+				"accessModes":      []any{"ReadWriteOnce"},
+				"storageClassName": "fast",
+				"resources":        map[string]any{"requests": map[string]any{"storage": "10Gi"}},
+			},
+		}}
+	}
+	desired := []render.Resource{pvc("n", "data-v2"), pvc("other", "moved"), configMap("fresh", map[string]any{"a": "1"})}
+	actual := []render.Resource{pvc("n", "data"), pvc("n", "moved"), configMap("stale", map[string]any{"b": "2"})}
+
+	changes, summary := Compute(desired, actual, Options{PruneDeletes: true})
+	if summary.Renames != 2 || summary.Creates != 1 || summary.Deletes != 1 {
+		t.Fatalf("expected two renames and an unrelated create/delete, got %+v %+v", summary, changes)
+	}
+	byID := map[string]Change{}
+	for _, c := range changes {
+		byID[c.ID] = c
+	}
+	renamed := byID["v1|PersistentVolumeClaim|n|data-v2"]
+	if renamed.Action != Rename || renamed.PreviousID != "v1|PersistentVolumeClaim|n|data" || !contains(renamed.Risks, "pvc-data-loss") {
+		t.Fatalf("expected rename with data loss risk, got %+v", renamed)
+	}
+	if len(renamed.ChangedPaths) != 1 || renamed.ChangedPaths[0] != "metadata.name" {
+		t.Fatalf("expected only the name to change, got %v", renamed.ChangedPaths)
+	}
+	if moved := byID["v1|PersistentVolumeClaim|other|moved"]; moved.Action != Move {
+		t.Fatalf("expected move across namespaces, got %+v", moved)
+	}
+
+	if _, summary := Compute(desired, actual, Options{}); summary.Renames != 0 {
+		t.Fatalf("expected no pairing without prune, got %+v", summary)
+	}
+}
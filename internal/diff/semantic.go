@@ -0,0 +1,277 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/thule/internal/render"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// valueKind is how a typed field of a built-in kind is compared.
+type valueKind int
+
+const (
+	quantityValue valueKind = iota + 1
+	durationValue
+	intOrStringValue
+)
+
+// podSpecPaths locate the pod spec of workload kinds, keyed by
+// "<group>/<kind>" with an empty core group.
+var podSpecPaths = map[string]string{
+	"/Pod":                   "spec",
+	"/PodTemplate":           "template.spec",
+	"/ReplicationController": "spec.template.spec",
+	"apps/Deployment":        "spec.template.spec",
+	"apps/ReplicaSet":        "spec.template.spec",
+	"apps/StatefulSet":       "spec.template.spec",
+	"apps/DaemonSet":         "spec.template.spec",
+	"batch/Job":              "spec.template.spec",
+	"batch/CronJob":          "spec.jobTemplate.spec.template.spec",
+}
+
+// podSpecFields are relative to the pod spec. List selectors are left out of
+// paths and "*" matches any map key.
+var podSpecFields = func() map[string]valueKind {
+	out := map[string]valueKind{
+		"resources.limits.*":         quantityValue,
+		"resources.requests.*":       quantityValue,
+		"overhead.*":                 quantityValue,
+		"volumes.emptyDir.sizeLimit": quantityValue,
+	}
+	for _, c := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		out[c+".resources.limits.*"] = quantityValue
+		out[c+".resources.requests.*"] = quantityValue
+		out[c+".ports.containerPort"] = intOrStringValue
+		out[c+".ports.hostPort"] = intOrStringValue
+		for _, handler := range []string{"livenessProbe", "readinessProbe", "startupProbe", "lifecycle.postStart", "lifecycle.preStop"} {
+			out[c+"."+handler+".httpGet.port"] = intOrStringValue
+			out[c+"."+handler+".tcpSocket.port"] = intOrStringValue
+		}
+	}
+	return out
+}()
+
+// kindFields are typed fields of other built-in kinds, from the object root.
+var kindFields = map[string]map[string]valueKind{
+	"apps/Deployment": {
+		"spec.strategy.rollingUpdate.maxSurge":       intOrStringValue,
+		"spec.strategy.rollingUpdate.maxUnavailable": intOrStringValue,
+	},
+	"apps/DaemonSet": {
+		"spec.updateStrategy.rollingUpdate.maxSurge":       intOrStringValue,
+		"spec.updateStrategy.rollingUpdate.maxUnavailable": intOrStringValue,
+	},
+	"apps/StatefulSet": {
+		"spec.updateStrategy.rollingUpdate.maxUnavailable":    intOrStringValue,
+		"spec.volumeClaimTemplates.spec.resources.limits.*":   quantityValue,
+		"spec.volumeClaimTemplates.spec.resources.requests.*": quantityValue,
+	},
+	"/Service": {
+		"spec.ports.port":       intOrStringValue,
+		"spec.ports.targetPort": intOrStringValue,
+		"spec.ports.nodePort":   intOrStringValue,
+	},
+	"/ResourceQuota": {
+		"spec.hard.*": quantityValue,
+	},
+	"/LimitRange": {
+		"spec.limits.max.*":                  quantityValue,
+		"spec.limits.min.*":                  quantityValue,
+		"spec.limits.default.*":              quantityValue,
+		"spec.limits.defaultRequest.*":       quantityValue,
+		"spec.limits.maxLimitRequestRatio.*": quantityValue,
+	},
+	"/PersistentVolumeClaim": {
+		"spec.resources.limits.*":   quantityValue,
+		"spec.resources.requests.*": quantityValue,
+	},
+	"/PersistentVolume": {
+		"spec.capacity.*": quantityValue,
+	},
+	"policy/PodDisruptionBudget": {
+		"spec.minAvailable":   intOrStringValue,
+		"spec.maxUnavailable": intOrStringValue,
+	},
+	"networking.k8s.io/NetworkPolicy": {
+		"spec.ingress.ports.port": intOrStringValue,
+		"spec.egress.ports.port":  intOrStringValue,
+	},
+}
+
+// fluxGroups are the Flux API groups whose kinds share the reconcile
+// interval and timeout fields.
+var fluxGroups = map[string]struct{}{
+	"kustomize.toolkit.fluxcd.io":    {},
+	"helm.toolkit.fluxcd.io":         {},
+	"source.toolkit.fluxcd.io":       {},
+	"image.toolkit.fluxcd.io":        {},
+	"notification.toolkit.fluxcd.io": {},
+}
+
+var fluxFields = map[string]valueKind{
+	"spec.interval":            durationValue,
+	"spec.retryInterval":       durationValue,
+	"spec.timeout":             durationValue,
+	"spec.chart.spec.interval": durationValue,
+}
+
+// typedFields returns the fields of r compared by value rather than by
+// spelling. Kinds that are neither built-in nor Flux (CRDs) have none.
+func typedFields(r render.Resource) map[string]valueKind {
+	group, _, ok := strings.Cut(r.APIVersion, "/")
+	if !ok {
+		group = ""
+	}
+	key := group + "/" + r.Kind
+	out := map[string]valueKind{}
+	for path, kind := range kindFields[key] {
+		out[path] = kind
+	}
+	if prefix, ok := podSpecPaths[key]; ok {
+		for path, kind := range podSpecFields {
+			out[prefix+"."+path] = kind
+		}
+	}
+	if _, ok := fluxGroups[group]; ok {
+		for path, kind := range fluxFields {
+			out[path] = kind
+		}
+	}
+	return out
+}
+
+// alignSpellings returns a copy of actual in which typed values that only
+// differ from desired in spelling ("1000m" and "1", "1Gi" and "1024Mi", "60s"
+// and "1m", 80 and "80") take the desired spelling. Comparisons run against
+// the copy; neither body is rewritten for display.
+func (m mergeKeys) alignSpellings(r render.Resource, desired, actual map[string]any) map[string]any {
+	if actual == nil {
+		return nil
+	}
+	fields := typedFields(r)
+	if len(fields) == 0 {
+		return actual
+	}
+	out, _ := m.alignValues(fields, "", desired, actual).(map[string]any)
+	return out
+}
+
+// alignValues walks desired and actual with list elements matched by merge
+// key; path has no list selectors.
+func (m mergeKeys) alignValues(fields map[string]valueKind, path string, desired, actual any) any {
+	switch av := actual.(type) {
+	case map[string]any:
+		dv, ok := desired.(map[string]any)
+		if !ok {
+			return actual
+		}
+		out := make(map[string]any, len(av))
+		for k, avv := range av {
+			next := k
+			if path != "" {
+				next = path + "." + k
+			}
+			if dvv, ok := dv[k]; ok {
+				out[k] = m.alignValues(fields, next, dvv, avv)
+			} else {
+				out[k] = avv
+			}
+		}
+		return out
+	case []any:
+		dv, ok := desired.([]any)
+		if !ok {
+			return actual
+		}
+		out := make([]any, len(av))
+		if key, _, ok := m.match(path, dv, av); ok {
+			bySegment := elementsBySegment(key, dv)
+			for i, item := range av {
+				if d, ok := bySegment[elementSegment(key, item)]; ok {
+					out[i] = m.alignValues(fields, path, d, item)
+				} else {
+					out[i] = item
+				}
+			}
+			return out
+		}
+		for i, item := range av {
+			if i < len(dv) {
+				out[i] = m.alignValues(fields, path, dv[i], item)
+			} else {
+				out[i] = item
+			}
+		}
+		return out
+	default:
+		kind, ok := fields[path]
+		if !ok {
+			if i := strings.LastIndex(path, "."); i >= 0 {
+				kind, ok = fields[path[:i]+".*"]
+			}
+		}
+		if ok && equalAny(canonicalValue(kind, desired), canonicalValue(kind, actual)) {
+			return desired
+		}
+		return actual
+	}
+}
+
+// canonicalValue rewrites a scalar to one spelling. Values that do not parse
+// are returned untouched.
+func canonicalValue(kind valueKind, v any) any {
+	switch kind {
+	case quantityValue:
+		return canonicalQuantity(v)
+	case durationValue:
+		return canonicalDuration(v)
+	case intOrStringValue:
+		return canonicalIntOrString(v)
+	}
+	return v
+}
+
+func canonicalQuantity(v any) any {
+	var raw string
+	switch q := v.(type) {
+	case string:
+		raw = q
+	case float64:
+		raw = strconv.FormatFloat(q, 'f', -1, 64)
+	default:
+		return v
+	}
+	parsed, err := resource.ParseQuantity(raw)
+	if err != nil {
+		return v
+	}
+	return parsed.String()
+}
+
+func canonicalDuration(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return v
+	}
+	return d.String()
+}
+
+func canonicalIntOrString(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || fmt.Sprint(n) != s {
+		return v
+	}
+	return float64(n)
+}
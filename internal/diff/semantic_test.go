@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestComputeCanonicalizesSemanticValues(t *testing.T) {
+	body := func(cpu, memory any, port, surge any) map[string]any {
+		return map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"namespace": "n", "name": "web"},
+			"spec": map[string]any{
+				"strategy": map[string]any{"rollingUpdate": map[string]any{"maxSurge": surge}},
+				"template": map[string]any{"spec": map[string]any{
+					"containers": []any{map[string]any{
+						"name":      "web",
+						"resources": map[string]any{"requests": map[string]any{"cpu": cpu, "memory": memory}},
+						"ports":     []any{map[string]any{"containerPort": port}},
+						"readinessProbe": map[string]any{
+							"httpGet": map[string]any{"port": port},
+						},
+					}},
+					"volumes": []any{map[string]any{"name": "tmp", "emptyDir": map[string]any{"sizeLimit": memory}}},
+				}},
+			},
+		}
+	}
+	desired := []render.Resource{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: body("1000m", "1Gi", 80, "25%")}}
+	actual := []render.Resource{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: body(1, "1024Mi", "80", "25%")}}
+	if changes, summary := Compute(desired, actual, Options{}); summary.NoOps != 1 {
+		t.Fatalf("expected equivalent spellings to be a no-op, got %+v", changes)
+	}
+
+	actual[0].Body = body("500m", "1024Mi", "80", "25%")
+	changes, summary := Compute(desired, actual, Options{})
+	if summary.Patches != 1 || len(changes[0].ChangedPaths) != 1 || changes[0].ChangedPaths[0] != "spec.template.spec.containers[name=web].resources.requests.cpu" {
+		t.Fatalf("expected only the cpu request to change, got %+v", changes)
+	}
+	wantDiff := []string{
+		`- spec.template.spec.containers[name=web].resources.requests.cpu: "500m"`,
+		`+ spec.template.spec.containers[name=web].resources.requests.cpu: "1000m"`,
+	}
+	if !reflect.DeepEqual(changes[0].AttributeDiff, wantDiff) {
+		t.Fatalf("expected the diff to show the values as written, got %q", changes[0].AttributeDiff)
+	}
+	if !strings.Contains(changes[0].CurrentYAML, "1024Mi") || !strings.Contains(changes[0].DesiredYAML, "1Gi") || !strings.Contains(changes[0].DesiredYAML, "1000m") {
+		t.Fatalf("expected bodies to keep their spellings, got current:\n%s\ndesired:\n%s", changes[0].CurrentYAML, changes[0].DesiredYAML)
+	}
+}
+
+func TestComputeComparesFluxDurationsByValue(t *testing.T) {
+	body := func(interval string) map[string]any {
+		return map[string]any{
+			"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+			"kind":       "Kustomization",
+			"metadata":   map[string]any{"namespace": "flux-system", "name": "apps"},
+			"spec":       map[string]any{"interval": interval, "path": "./apps"},
+		}
+	}
+	desired := []render.Resource{{APIVersion: "kustomize.toolkit.fluxcd.io/v1", Kind: "Kustomization", Namespace: "flux-system", Name: "apps", Body: body("60s")}}
+	actual := []render.Resource{{APIVersion: "kustomize.toolkit.fluxcd.io/v1", Kind: "Kustomization", Namespace: "flux-system", Name: "apps", Body: body("1m0s")}}
+	if changes, summary := Compute(desired, actual, Options{}); summary.NoOps != 1 {
+		t.Fatalf("expected equivalent intervals to be a no-op, got %+v", changes)
+	}
+}
+
+func TestComputeComparesCustomResourcesBySpelling(t *testing.T) {
+	body := func(cpu string) map[string]any {
+		return map[string]any{
+			"apiVersion": "example.com/v1",
+			"kind":       "Sandbox",
+			"metadata":   map[string]any{"namespace": "n", "name": "box"},
+			"spec":       map[string]any{"limits": map[string]any{"cpu": cpu}, "timeout": "60s"},
+		}
+	}
+	desired := []render.Resource{{APIVersion: "example.com/v1", Kind: "Sandbox", Namespace: "n", Name: "box", Body: body("1000m")}}
+	actual := []render.Resource{{APIVersion: "example.com/v1", Kind: "Sandbox", Namespace: "n", Name: "box", Body: body("1")}}
+	changes, summary := Compute(desired, actual, Options{})
+	if summary.Patches != 1 || !reflect.DeepEqual(changes[0].ChangedPaths, []string{"spec.limits.cpu"}) {
+		t.Fatalf("expected custom resource fields to compare as written, got %+v", changes)
+	}
+}
+
+func TestCanonicalValueLeavesUnparseableValues(t *testing.T) {
+	cases := []struct {
+		kind valueKind
+		in   any
+	}{
+		{durationValue, "soon"},
+		{durationValue, 5.0},
+		{intOrStringValue, "http"},
+		{intOrStringValue, "080"},
+		{quantityValue, "lots"},
+	}
+	for _, c := range cases {
+		if out := canonicalValue(c.kind, c.in); out != c.in {
+			t.Fatalf("expected %v untouched, got %v", c.in, out)
+		}
+	}
+}
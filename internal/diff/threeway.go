@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"sort"
+
+	"github.com/example/thule/internal/render"
+)
+
+// Origin tells whether a change comes from the merge request, from the live
+// cluster having drifted from the target branch, or from both.
+type Origin string
+
+const (
+	OriginMR    Origin = "mr"
+	OriginDrift Origin = "drift"
+	OriginBoth  Origin = "both"
+)
+
+// ComputeThreeWay diffs the head render against live state like Compute and
+// uses the merge base render to classify each change and changed path by
+// Origin.
+func ComputeThreeWay(base, desired, actual []render.Resource, opts Options) ([]Change, Summary) {
+	bm := map[string]render.Resource{}
+	ignore := newIgnoreRules(opts.IgnoreFields)
+	for _, r := range base {
+		bm[r.ID()] = normalize(r, ignore)
+	}
+	return compute(desired, actual, bm, opts)
+}
+
+// existenceOrigin classifies a CREATE (desired) or DELETE (!desired): it was
+// introduced by the MR unless the merge base already agreed with head.
+func existenceOrigin(base map[string]render.Resource, id string, desired bool) Origin {
+	_, inBase := base[id]
+	if inBase == desired {
+		return OriginDrift
+	}
+	return OriginMR
+}
+
+// classifyPaths walks the same paths as changedFieldPaths and compares the
+// merge base value at each one with head and live.
+func (m mergeKeys) classifyPaths(desired, base, actual any) map[string]Origin {
+	out := map[string]Origin{}
+	var walk func(path string, d, b, a any)
+	walk = func(path string, d, b, a any) {
+		if equalAny(d, a) {
+			return
+		}
+		dm, dok := d.(map[string]any)
+		am, aok := a.(map[string]any)
+		if dok && aok {
+			bm, _ := b.(map[string]any)
+			keys := map[string]struct{}{}
+			for k := range dm {
+				keys[k] = struct{}{}
+			}
+			for k := range am {
+				keys[k] = struct{}{}
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				next := k
+				if path != "" {
+					next = path + "." + k
+				}
+				walk(next, dm[k], bm[k], am[k])
+			}
+			return
+		}
+		ds, dok := d.([]any)
+		as, aok := a.([]any)
+		if dok && aok {
+			if key, pairs, ok := m.match(path, ds, as); ok {
+				bySegment := elementsBySegment(key, b)
+				for _, p := range pairs {
+					walk(path+p.segment, p.d, bySegment[p.segment], p.a)
+				}
+				return
+			}
+		}
+		if path == "" {
+			path = "<root>"
+		}
+		switch mrChanged, drifted := !equalAny(d, b), !equalAny(b, a); {
+		case mrChanged && drifted:
+			out[path] = OriginBoth
+		case mrChanged:
+			out[path] = OriginMR
+		default:
+			out[path] = OriginDrift
+		}
+	}
+	walk("", desired, base, actual)
+	return out
+}
+
+func combineOrigins(origins map[string]Origin) Origin {
+	combined := Origin("")
+	for _, o := range origins {
+		switch {
+		case combined == "":
+			combined = o
+		case combined != o:
+			return OriginBoth
+		}
+	}
+	return combined
+}
+
+// PathsByOrigin returns the changed paths of c attributed to origin, counting
+// OriginBoth paths for either side.
+func PathsByOrigin(c Change, origin Origin) []string {
+	out := []string{}
+	for p, o := range c.PathOrigins {
+		if o == origin || o == OriginBoth {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func configMap(name string, data map[string]any) render.Resource {
+	return render.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "n", Name: name, Body: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"namespace": "n", "name": name},
+		"data":       data,
+	}}
+}
+
+func TestComputeThreeWayClassifiesOrigins(t *testing.T) {
+	base := []render.Resource{
+		configMap("patched", map[string]any{"mr": "old", "drift": "x", "both": "old"}),
+		configMap("recreated", map[string]any{"a": "1"}),
+		configMap("removed", map[string]any{"a": "1"}),
+	}
+	desired := []render.Resource{
+		configMap("patched", map[string]any{"mr": "new", "drift": "x", "both": "new"}),
+		configMap("recreated", map[string]any{"a": "1"}),
+		configMap("added", map[string]any{"a": "1"}),
+	}
+	actual := []render.Resource{
+		configMap("patched", map[string]any{"mr": "old", "drift": "hand-edited", "both": "hand-edited"}),
+		configMap("removed", map[string]any{"a": "1"}),
+		configMap("stray", map[string]any{"a": "1"}),
+	}
+	changes, summary := ComputeThreeWay(base, desired, actual, Options{PruneDeletes: true})
+	if summary.Creates != 2 || summary.Patches != 1 || summary.Deletes != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	byName := map[string]Change{}
+	for _, c := range changes {
+		byName[c.ID[len("v1|ConfigMap|n|"):]] = c
+	}
+	for name, want := range map[string]Origin{"patched": OriginBoth, "recreated": OriginDrift, "added": OriginMR, "removed": OriginMR, "stray": OriginDrift} {
+		if got := byName[name].Origin; got != want {
+			t.Fatalf("%s: expected origin %q, got %q", name, want, got)
+		}
+	}
+	patched := byName["patched"]
+	for path, want := range map[string]Origin{"data.mr": OriginMR, "data.drift": OriginDrift, "data.both": OriginBoth} {
+		if got := patched.PathOrigins[path]; got != want {
+			t.Fatalf("%s: expected %q, got %q (%+v)", path, want, got, patched.PathOrigins)
+		}
+	}
+	if got := PathsByOrigin(patched, OriginDrift); len(got) != 2 || got[0] != "data.both" || got[1] != "data.drift" {
+		t.Fatalf("unexpected drift paths: %+v", got)
+	}
+}
+
+func TestComputeLeavesOriginUnsetWithoutBase(t *testing.T) {
+	changes, _ := Compute([]render.Resource{configMap("a", map[string]any{"k": "1"})}, []render.Resource{configMap("a", map[string]any{"k": "2"})}, Options{})
+	if len(changes) != 1 || changes[0].Origin != "" || changes[0].PathOrigins != nil {
+		t.Fatalf("expected no origins in two-way diff: %+v", changes)
+	}
+	if got := combineOrigins(map[string]Origin{"a": OriginMR, "b": OriginMR}); got != OriginMR {
+		t.Fatalf("expected uniform origin, got %q", got)
+	}
+}
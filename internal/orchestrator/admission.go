@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/policy"
+	"github.com/example/thule/internal/render"
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+// AdmissionPolicyReader is implemented by cluster readers that can list the
+// ValidatingAdmissionPolicies and bindings installed in a cluster.
+type AdmissionPolicyReader interface {
+	ListAdmissionPolicies(ctx context.Context, projectID, clusterRef string) ([]render.Resource, error)
+}
+
+// liveAdmissionPolicies returns the admission policies and bindings of a
+// project's cluster, cached per clusterRef in cache for the duration of a
+// plan. Git-only projects and clusters that cannot be listed have none.
+func (p *Planner) liveAdmissionPolicies(ctx context.Context, cfg thuleconfig.Config, gitOnly bool, cache map[string][]render.Resource) []render.Resource {
+	if gitOnly {
+		return nil
+	}
+	if policies, ok := cache[cfg.ClusterRef]; ok {
+		return policies
+	}
+	reader, ok := p.cluster.(AdmissionPolicyReader)
+	if !ok {
+		return nil
+	}
+	policies, err := reader.ListAdmissionPolicies(ctx, cfg.Project, cfg.ClusterRef)
+	if err != nil {
+		log.Printf("admission policies unavailable project=%s cluster=%s err=%v", cfg.Project, cfg.ClusterRef, err)
+	}
+	cache[cfg.ClusterRef] = policies
+	return policies
+}
+
+// resolveAdmissionLookups fetches the policy params and Namespaces the
+// admission evaluator needs for desired and changes that neither the render
+// nor the live state holds. Git-only projects and readers that cannot fetch
+// individual objects leave the evaluator unresolved, so params it cannot
+// find are only warnings.
+func (p *Planner) resolveAdmissionLookups(ctx context.Context, cfg thuleconfig.Config, gitOnly bool, e *policy.AdmissionEvaluator, desired []render.Resource, changes []diff.Change) {
+	if gitOnly {
+		return
+	}
+	projectAware, ok := p.cluster.(ProjectAwareClusterReader)
+	if !ok {
+		return
+	}
+	wanted := e.Lookups(desired, changes)
+	if len(wanted) == 0 {
+		e.Resolve(nil)
+		return
+	}
+	found, err := projectAware.ListResourcesWithProject(ctx, cfg.Project, cfg.ClusterRef, cfg.Namespace, wanted)
+	if err != nil {
+		log.Printf("admission lookup failed project=%s err=%v", cfg.Project, err)
+		return
+	}
+	e.Resolve(found)
+}
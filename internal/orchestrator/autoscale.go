@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/example/thule/internal/render"
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+// AutoscalerReader is implemented by cluster readers that can list the
+// autoscalers installed in namespaces of a cluster.
+type AutoscalerReader interface {
+	ListAutoscalers(ctx context.Context, projectID, clusterRef string, namespaces []string) ([]render.Resource, error)
+}
+
+// autoscalers lists the kinds that own a workload's replica count through
+// spec.scaleTargetRef, with the kind a target defaults to when unset.
+var autoscalers = map[string]string{
+	"HorizontalPodAutoscaler": "",
+	"ScaledObject":            "Deployment", // KEDA
+}
+
+// autoscaledTargets maps workloads (kind|namespace|name) targeted by an
+// autoscaler in any of the given sets to a description of that autoscaler.
+func autoscaledTargets(sets ...[]render.Resource) map[string]string {
+	out := map[string]string{}
+	for _, set := range sets {
+		for _, r := range set {
+			defaultKind, ok := autoscalers[r.Kind]
+			if !ok {
+				continue
+			}
+			spec, _ := r.Body["spec"].(map[string]any)
+			ref, _ := spec["scaleTargetRef"].(map[string]any)
+			name, _ := ref["name"].(string)
+			kind, _ := ref["kind"].(string)
+			if kind == "" {
+				kind = defaultKind
+			}
+			if name == "" || kind == "" {
+				continue
+			}
+			out[scaleTargetKey(kind, r.Namespace, name)] = fmt.Sprintf("%s `%s/%s`", r.Kind, r.Namespace, r.Name)
+		}
+	}
+	return out
+}
+
+// liveAutoscalers returns the autoscalers in the namespaces of desired, so
+// workloads scaled by an autoscaler only the cluster holds are recognised.
+// Git-only projects and clusters that cannot be listed have none.
+func (p *Planner) liveAutoscalers(ctx context.Context, cfg thuleconfig.Config, gitOnly bool, desired []render.Resource) []render.Resource {
+	if gitOnly {
+		return nil
+	}
+	reader, ok := p.cluster.(AutoscalerReader)
+	if !ok {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	namespaces := []string{}
+	for _, r := range desired {
+		if _, ok := seen[r.Namespace]; ok || r.Namespace == "" {
+			continue
+		}
+		seen[r.Namespace] = struct{}{}
+		namespaces = append(namespaces, r.Namespace)
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+	sort.Strings(namespaces)
+	autoscalers, err := reader.ListAutoscalers(ctx, cfg.Project, cfg.ClusterRef, namespaces)
+	if err != nil {
+		log.Printf("autoscalers unavailable project=%s cluster=%s err=%v", cfg.Project, cfg.ClusterRef, err)
+	}
+	return autoscalers
+}
+
+func scaleTargetKey(kind, namespace, name string) string {
+	return kind + "|" + namespace + "|" + name
+}
+
+// withoutAutoscaledReplicas drops spec.replicas from workloads an autoscaler
+// scales, leaving the input untouched, and describes what it dropped.
+func withoutAutoscaledReplicas(resources []render.Resource, targets map[string]string) ([]render.Resource, []string) {
+	if len(targets) == 0 {
+		return resources, nil
+	}
+	out := make([]render.Resource, 0, len(resources))
+	notes := []string{}
+	for _, r := range resources {
+		scaler, ok := targets[scaleTargetKey(r.Kind, r.Namespace, r.Name)]
+		spec, _ := r.Body["spec"].(map[string]any)
+		if _, hasReplicas := spec["replicas"]; !ok || !hasReplicas {
+			out = append(out, r)
+			continue
+		}
+		body := make(map[string]any, len(r.Body))
+		for k, v := range r.Body {
+			body[k] = v
+		}
+		trimmed := make(map[string]any, len(spec))
+		for k, v := range spec {
+			if k != "replicas" {
+				trimmed[k] = v
+			}
+		}
+		body["spec"] = trimmed
+		r.Body = body
+		out = append(out, r)
+		notes = append(notes, fmt.Sprintf("`spec.replicas` of %s (scaled by %s)", r.ID(), scaler))
+	}
+	return out, notes
+}
+
+// suppressAutoscaledReplicas applies withoutAutoscaledReplicas to the desired,
+// live and merge base sets and returns the notes once per workload.
+func suppressAutoscaledReplicas(targets map[string]string, desired, actual, base *[]render.Resource) []string {
+	seen := map[string]struct{}{}
+	for _, set := range []*[]render.Resource{desired, actual, base} {
+		var notes []string
+		*set, notes = withoutAutoscaledReplicas(*set, targets)
+		for _, n := range notes {
+			seen[n] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for n := range seen {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
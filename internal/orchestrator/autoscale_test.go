@@ -0,0 +1,46 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestSuppressAutoscaledReplicas(t *testing.T) {
+	deployment := func(name string, replicas float64) render.Resource {
+		return render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: name, Body: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"namespace": "n", "name": name},
+			"spec":       map[string]any{"replicas": replicas, "paused": false},
+		}}
+	}
+	hpa := render.Resource{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Namespace: "n", Name: "web", Body: map[string]any{
+		"spec": map[string]any{"scaleTargetRef": map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web"}},
+	}}
+	scaledObject := render.Resource{APIVersion: "keda.sh/v1alpha1", Kind: "ScaledObject", Namespace: "n", Name: "worker", Body: map[string]any{
+		"spec": map[string]any{"scaleTargetRef": map[string]any{"name": "worker"}},
+	}}
+
+	desired := []render.Resource{deployment("web", 2), deployment("worker", 1), deployment("fixed", 3)}
+	actual := []render.Resource{deployment("web", 7), deployment("worker", 4), deployment("fixed", 3), scaledObject}
+	var base []render.Resource
+	original := desired[0].Body["spec"].(map[string]any)
+
+	notes := suppressAutoscaledReplicas(autoscaledTargets([]render.Resource{hpa}, actual, base), &desired, &actual, &base)
+	if len(notes) != 2 || !strings.Contains(notes[0], "Deployment|n|web") || !strings.Contains(notes[0], "HorizontalPodAutoscaler `n/web`") || !strings.Contains(notes[1], "ScaledObject `n/worker`") {
+		t.Fatalf("unexpected notes %v", notes)
+	}
+	for _, set := range [][]render.Resource{desired, actual[:3]} {
+		for _, r := range set {
+			_, has := r.Body["spec"].(map[string]any)["replicas"]
+			if has != (r.Name == "fixed") {
+				t.Fatalf("unexpected replicas on %s: %+v", r.Name, r.Body)
+			}
+		}
+	}
+	if _, ok := original["replicas"]; !ok {
+		t.Fatal("expected the rendered body to be left untouched")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/example/thule/internal/render"
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	api "google.golang.org/api/container/v1"
@@ -42,8 +43,9 @@ type LiveClusterReader struct {
 const liveRequestTimeout = 15 * time.Second
 
 type liveClient struct {
-	dynamic dynamic.Interface
-	mapper  *restmapper.DeferredDiscoveryRESTMapper
+	dynamic   dynamic.Interface
+	discovery discovery.DiscoveryInterface
+	mapper    *restmapper.DeferredDiscoveryRESTMapper
 }
 
 func NewLiveClusterReader() (*LiveClusterReader, error) {
@@ -70,6 +72,112 @@ func (l *LiveClusterReader) ListResources(_ context.Context, _ string, _ string)
 	return nil, nil
 }
 
+// OpenAPISchema fetches the OpenAPI v2 document the cluster serves, which
+// covers its Kubernetes version and installed CRDs.
+func (l *LiveClusterReader) OpenAPISchema(ctx context.Context, projectID, clusterRef string) (*openapi_v2.Document, error) {
+	client, err := l.getClient(ctx, projectID, clusterRef)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.discovery.OpenAPISchema()
+	if err != nil {
+		return nil, fmt.Errorf("openapi schema for %s/%s: %w", projectID, clusterRef, err)
+	}
+	return doc, nil
+}
+
+// ServerVersion reads the cluster's Kubernetes version from its version
+// endpoint.
+func (l *LiveClusterReader) ServerVersion(ctx context.Context, projectID, clusterRef string) (string, error) {
+	client, err := l.getClient(ctx, projectID, clusterRef)
+	if err != nil {
+		return "", err
+	}
+	info, err := client.discovery.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("server version for %s/%s: %w", projectID, clusterRef, err)
+	}
+	return info.GitVersion, nil
+}
+
+// admissionPolicyResources are listed for offline admission checks.
+var admissionPolicyResources = []schema.GroupVersionResource{
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingadmissionpolicies"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingadmissionpolicybindings"},
+}
+
+// ListAdmissionPolicies lists the ValidatingAdmissionPolicies and bindings
+// installed in the cluster. Clusters that do not serve them or forbid listing
+// them have none.
+func (l *LiveClusterReader) ListAdmissionPolicies(ctx context.Context, projectID, clusterRef string) ([]render.Resource, error) {
+	client, err := l.getClient(ctx, projectID, clusterRef)
+	if err != nil {
+		return nil, err
+	}
+	out := []render.Resource{}
+	for _, gvr := range admissionPolicyResources {
+		reqCtx, cancel := context.WithTimeout(ctx, liveRequestTimeout)
+		list, err := client.dynamic.Resource(gvr).List(reqCtx, metav1.ListOptions{})
+		cancel()
+		if errors.IsForbidden(err) || errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list live %s: %w", gvr.Resource, err)
+		}
+		for _, obj := range list.Items {
+			out = append(out, render.Resource{
+				APIVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Name:       obj.GetName(),
+				Body:       obj.Object,
+			})
+		}
+	}
+	return out, nil
+}
+
+// autoscalerResources are listed so workloads scaled by autoscalers the
+// render does not declare are recognised.
+var autoscalerResources = []schema.GroupVersionResource{
+	{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+	{Group: "keda.sh", Version: "v1alpha1", Resource: "scaledobjects"},
+}
+
+// ListAutoscalers lists the HorizontalPodAutoscalers and KEDA ScaledObjects
+// in namespaces. Kinds the cluster does not serve or forbids listing are
+// skipped.
+func (l *LiveClusterReader) ListAutoscalers(ctx context.Context, projectID, clusterRef string, namespaces []string) ([]render.Resource, error) {
+	client, err := l.getClient(ctx, projectID, clusterRef)
+	if err != nil {
+		return nil, err
+	}
+	out := []render.Resource{}
+	for _, gvr := range autoscalerResources {
+		for _, ns := range namespaces {
+			reqCtx, cancel := context.WithTimeout(ctx, liveRequestTimeout)
+			list, err := client.dynamic.Resource(gvr).Namespace(ns).List(reqCtx, metav1.ListOptions{})
+			cancel()
+			if errors.IsForbidden(err) || errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("list live %s in %s: %w", gvr.Resource, ns, err)
+			}
+			for _, obj := range list.Items {
+				out = append(out, render.Resource{
+					APIVersion: obj.GetAPIVersion(),
+					Kind:       obj.GetKind(),
+					Namespace:  obj.GetNamespace(),
+					Name:       obj.GetName(),
+					Body:       obj.Object,
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
 func (l *LiveClusterReader) ListResourcesWithProject(ctx context.Context, projectID, clusterRef, namespace string, desired []render.Resource) ([]render.Resource, error) {
 	client, err := l.getClient(ctx, projectID, clusterRef)
 	if err != nil {
@@ -128,6 +236,89 @@ func (l *LiveClusterReader) ListResourcesWithProject(ctx context.Context, projec
 			Body:       obj.Object,
 		})
 	}
+	owned, err := listFluxOwnedObjects(ctx, client, desired, out)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, owned...), nil
+}
+
+// fluxOwners are the label pairs helm-controller and kustomize-controller
+// stamp on the objects of a HelmRelease or Kustomization.
+var fluxOwners = []struct {
+	kind, nameLabel, namespaceLabel string
+}{
+	{kind: "HelmRelease", nameLabel: render.HelmReleaseNameLabel, namespaceLabel: render.HelmReleaseNamespaceLabel},
+	{kind: "Kustomization", nameLabel: render.KustomizationNameLabel, namespaceLabel: render.KustomizationNamespaceLabel},
+}
+
+// listFluxOwnedObjects finds live objects labelled by Flux controllers for the
+// HelmReleases and Kustomizations expanded in desired, so objects dropped from
+// a chart or kustomization are visible to the diff. Only kinds rendered for
+// the owner are listed; siblings still rendered but filtered out of desired
+// are dropped by the planner (see withoutUnplanned).
+func listFluxOwnedObjects(ctx context.Context, client *liveClient, desired, seen []render.Resource) ([]render.Resource, error) {
+	type ownedKind struct {
+		apiVersion, kind, selector, owner, parent string
+	}
+	targets := map[ownedKind]struct{}{}
+	for _, d := range desired {
+		meta, _ := d.Body["metadata"].(map[string]any)
+		labels, _ := meta["labels"].(map[string]any)
+		for _, o := range fluxOwners {
+			name, _ := labels[o.nameLabel].(string)
+			ns, _ := labels[o.namespaceLabel].(string)
+			if name == "" {
+				continue
+			}
+			targets[ownedKind{
+				apiVersion: d.APIVersion,
+				kind:       d.Kind,
+				selector:   fmt.Sprintf("%s=%s,%s=%s", o.nameLabel, name, o.namespaceLabel, ns),
+				owner:      fmt.Sprintf("%s %s/%s", o.kind, ns, name),
+				parent:     d.Parent,
+			}] = struct{}{}
+		}
+	}
+	known := map[string]struct{}{}
+	for _, r := range seen {
+		known[r.ID()] = struct{}{}
+	}
+	out := []render.Resource{}
+	for t := range targets {
+		gv, err := schema.ParseGroupVersion(t.apiVersion)
+		if err != nil {
+			continue
+		}
+		mapping, err := client.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: t.kind}, gv.Version)
+		if err != nil {
+			continue
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, liveRequestTimeout)
+		list, err := client.dynamic.Resource(mapping.Resource).List(reqCtx, metav1.ListOptions{LabelSelector: t.selector})
+		cancel()
+		if errors.IsForbidden(err) || errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list live objects for %s: %w", t.owner, err)
+		}
+		for _, obj := range list.Items {
+			r := render.Resource{
+				APIVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+				Body:       obj.Object,
+				Parent:     t.parent,
+			}
+			if _, ok := known[r.ID()]; ok {
+				continue
+			}
+			known[r.ID()] = struct{}{}
+			out = append(out, r)
+		}
+	}
 	return out, nil
 }
 
@@ -232,8 +423,9 @@ func newLiveClient(cfg *rest.Config, ref string) (*liveClient, error) {
 		return nil, fmt.Errorf("discovery client for %s: %w", ref, err)
 	}
 	return &liveClient{
-		dynamic: dc,
-		mapper:  restmapper.NewDeferredDiscoveryRESTMapper(cached.NewMemCacheClient(disc)),
+		dynamic:   dc,
+		discovery: disc,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(cached.NewMemCacheClient(disc)),
 	}, nil
 }
 
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+// VersionReader is implemented by cluster readers that can ask a cluster for
+// its Kubernetes version.
+type VersionReader interface {
+	ServerVersion(ctx context.Context, projectID, clusterRef string) (string, error)
+}
+
+// kubeVersion returns the Kubernetes version a project's cluster runs: the
+// version the live cluster reports, or else the configured kubeVersion (for
+// git-only projects and unreachable clusters). Live versions are cached per
+// clusterRef in cache for the duration of a plan. It returns "" when the
+// version is unknown.
+func (p *Planner) kubeVersion(ctx context.Context, cfg thuleconfig.Config, gitOnly bool, cache map[string]string) string {
+	if gitOnly {
+		return cfg.KubeVersion
+	}
+	v, ok := cache[cfg.ClusterRef]
+	if !ok {
+		if reader, isVersioned := p.cluster.(VersionReader); isVersioned {
+			var err error
+			if v, err = reader.ServerVersion(ctx, cfg.Project, cfg.ClusterRef); err != nil {
+				log.Printf("cluster version unavailable project=%s cluster=%s err=%v", cfg.Project, cfg.ClusterRef, err)
+			}
+		}
+		cache[cfg.ClusterRef] = v
+	}
+	if v == "" {
+		return cfg.KubeVersion
+	}
+	return v
+}
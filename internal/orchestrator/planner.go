@@ -3,10 +3,12 @@ package orchestrator
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/example/thule/internal/config"
 	"github.com/example/thule/internal/diff"
@@ -15,7 +17,9 @@ import (
 	"github.com/example/thule/internal/render"
 	"github.com/example/thule/internal/report"
 	"github.com/example/thule/internal/run"
+	"github.com/example/thule/internal/schema"
 	"github.com/example/thule/internal/vcs"
+	"github.com/example/thule/pkg/thuleconfig"
 )
 
 type ClusterReader interface {
@@ -26,25 +30,66 @@ type ProjectAwareClusterReader interface {
 	ListResourcesWithProject(ctx context.Context, projectID, clusterRef, namespace string, desired []render.Resource) ([]render.Resource, error)
 }
 
+// BaseTreeReader materializes the merge base of a merge request so resources
+// removed by the MR can be found by rendering both sides.
+type BaseTreeReader interface {
+	MergeBaseTree(ctx context.Context, baseRef, headSHA string) (dir string, cleanup func(), err error)
+}
+
 type Planner struct {
-	repoRoot   string
-	cluster    ClusterReader
-	comments   vcs.CommentStore
-	status     vcs.StatusPublisher
-	runs       run.Store
-	policyEval policy.Evaluator
+	repoRoot        string
+	cluster         ClusterReader
+	comments        vcs.CommentStore
+	status          vcs.StatusPublisher
+	runs            run.Store
+	policyEval      policy.Evaluator
+	baseTrees       BaseTreeReader
+	gitOnlyClusters map[string]struct{}
+	exceptionsPath  string
 }
 
 func NewPlanner(repoRoot string, cluster ClusterReader, comments vcs.CommentStore, status vcs.StatusPublisher, runs run.Store, policyEval policy.Evaluator) *Planner {
 	return &Planner{repoRoot: repoRoot, cluster: cluster, comments: comments, status: status, runs: runs, policyEval: policyEval}
 }
 
+// SetBaseTrees enables rendering projects at the merge base to detect
+// resources removed by the MR.
+func (p *Planner) SetBaseTrees(baseTrees BaseTreeReader) {
+	p.baseTrees = baseTrees
+}
+
+// SetGitOnlyClusters makes projects targeting these cluster refs diff against
+// the merge base render instead of the live cluster, as with diff.mode
+// git-only.
+func (p *Planner) SetGitOnlyClusters(clusterRefs []string) {
+	p.gitOnlyClusters = map[string]struct{}{}
+	for _, ref := range clusterRefs {
+		p.gitOnlyClusters[ref] = struct{}{}
+	}
+}
+
+// SetPolicyExceptions waives findings matched by the exceptions file at path
+// (relative to the repository root unless absolute), read from the MR head
+// on every plan.
+func (p *Planner) SetPolicyExceptions(path string) {
+	p.exceptionsPath = path
+}
+
+func (p *Planner) gitOnly(cfg thuleconfig.Config) bool {
+	if cfg.Diff.Mode == thuleconfig.DiffModeGitOnly {
+		return true
+	}
+	_, ok := p.gitOnlyClusters[cfg.ClusterRef]
+	return ok
+}
+
 func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error {
 	if p.runs != nil {
 		p.runs.SetLatestSHA(evt.MergeReqID, evt.HeadSHA)
 	}
 	if p.status != nil {
 		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: "thule/plan", State: vcs.CheckPending, Description: "Thule plan running"})
+		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: policyStatusContext, State: vcs.CheckPending, Description: "Thule policy evaluation running"})
 	}
 
 	projects := project.DiscoverFromChangedFiles(evt.ChangedFiles)
@@ -65,6 +110,33 @@ func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error
 			}
 		}
 	}
+	baseRoot := ""
+	baseErr := fmt.Errorf("merge base trees are not configured")
+	if p.baseTrees != nil && len(projects) > 0 {
+		dir, cleanup, err := p.baseTrees.MergeBaseTree(ctx, evt.BaseRef, evt.HeadSHA)
+		if err != nil {
+			baseErr = err
+			log.Printf("merge base render disabled mr=%d sha=%s err=%v", evt.MergeReqID, evt.HeadSHA, err)
+		} else {
+			defer cleanup()
+			baseRoot = dir
+		}
+	}
+	var exceptions []policy.Exception
+	exceptionsPath := p.exceptionsPath
+	if exceptionsPath != "" {
+		if !filepath.IsAbs(exceptionsPath) {
+			exceptionsPath = filepath.Join(p.repoRoot, exceptionsPath)
+		}
+		var err error
+		if exceptions, err = policy.LoadExceptions(exceptionsPath); err != nil {
+			p.finishWithError(evt, 0, err)
+			return err
+		}
+	}
+	liveSchemas := map[string]*schema.Validator{}
+	liveVersions := map[string]string{}
+	livePolicies := map[string][]render.Resource{}
 	for _, prj := range projects {
 		if p.runs != nil && p.runs.IsStale(evt.MergeReqID, evt.HeadSHA) {
 			return nil
@@ -84,14 +156,31 @@ func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error
 			maxResourceDetails = cfg.Comment.MaxResourceDetails
 		}
 
-		desired, err := render.RenderProject(filepath.Join(p.repoRoot, prj.Root), cfg)
+		gitOnly := p.gitOnly(cfg)
+		kubeVersion := p.kubeVersion(ctx, cfg, gitOnly, liveVersions)
+		desired, err := render.RenderProjectWithOptions(filepath.Join(p.repoRoot, prj.Root), cfg, render.Options{RepoRoot: p.repoRoot, KubeVersion: kubeVersion})
 		if err != nil {
 			failRuns(0, err)
 			p.finishWithError(evt, 0, err)
 			return err
 		}
+		var base []render.Resource
+		if baseRoot != "" {
+			base, err = renderAtBase(baseRoot, prj, kubeVersion)
+		} else {
+			err = baseErr
+		}
+		baseRendered := err == nil
+		if err != nil && gitOnly {
+			err = fmt.Errorf("git-only plan for %s needs the merge base render: %w", cfg.Project, err)
+			failRuns(0, err)
+			p.finishWithError(evt, 0, err)
+			return err
+		}
+		removed := removedResources(base, desired)
+		rendered := desired
 		desired = filterDesiredByChangedFiles(desired, evt.ChangedFiles, p.repoRoot)
-		if len(desired) == 0 {
+		if len(desired) == 0 && len(removed) == 0 {
 			continue
 		}
 
@@ -101,8 +190,12 @@ func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error
 			runIDs = append(runIDs, rr.ID)
 		}
 		var actual []render.Resource
-		if projectAware, ok := p.cluster.(ProjectAwareClusterReader); ok {
-			actual, err = projectAware.ListResourcesWithProject(ctx, cfg.Project, cfg.ClusterRef, cfg.Namespace, desired)
+		if gitOnly {
+			// The merge base render stands in for the cluster; only the
+			// resources under plan are compared so the rest are not deletes.
+			actual = append(selectByID(base, desired), removed...)
+		} else if projectAware, ok := p.cluster.(ProjectAwareClusterReader); ok {
+			actual, err = projectAware.ListResourcesWithProject(ctx, cfg.Project, cfg.ClusterRef, cfg.Namespace, append(append([]render.Resource{}, desired...), removed...))
 		} else {
 			actual, err = p.cluster.ListResources(ctx, cfg.ClusterRef, cfg.Namespace)
 		}
@@ -111,21 +204,56 @@ func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error
 			p.finishWithError(evt, rr.ID, err)
 			return err
 		}
-		changes, summary := diff.Compute(desired, actual, diff.Options{
+		live := actual
+		// Readers may return live objects of resources the MR leaves alone,
+		// e.g. Flux-owned siblings of a changed template; they are not deletes.
+		actual = withoutUnplanned(actual, rendered, desired)
+		// Replica counts of autoscaled workloads always differ from git.
+		suppressed := suppressAutoscaledReplicas(autoscaledTargets(rendered, actual, base, p.liveAutoscalers(ctx, cfg, gitOnly, desired)), &desired, &actual, &base)
+		diffOpts := diff.Options{
 			PruneDeletes:            cfg.Diff.Prune,
 			IgnoreFields:            cfg.Diff.IgnoreFields,
-			IgnoreActualExtraFields: true,
-		})
+			IgnoreActualExtraFields: !gitOnly,
+			MergeKeys:               cfg.Diff.MergeKeys,
+			FieldManagers:           cfg.Diff.FieldManagers,
+		}
+		var changes []diff.Change
+		var summary diff.Summary
+		if baseRendered && !gitOnly {
+			// Separate what the MR changes from live drift off the target branch.
+			changes, summary = diff.ComputeThreeWay(base, desired, actual, diffOpts)
+		} else {
+			changes, summary = diff.Compute(desired, actual, diffOpts)
+		}
 
 		findings := []policy.Finding{}
-		if p.policyEval != nil {
+		if changeAware, ok := p.policyEval.(policy.ChangeEvaluator); ok {
+			findings = changeAware.EvaluateChanges(desired, changes, cfg.Policy.Profile)
+		} else if p.policyEval != nil {
 			findings = p.policyEval.Evaluate(desired, cfg.Policy.Profile)
 		}
+		var liveLookup func(render.Reference) bool
+		if !gitOnly {
+			liveLookup = p.liveReferenceLookup(ctx, cfg, desired, rendered, live)
+		}
+		findings = append(findings, policy.CheckReferences(desired, rendered, removed, liveLookup)...)
+		findings = append(findings, policy.CheckUnresolvedSources(desired)...)
+		admission := policy.NewAdmissionEvaluator(rendered, append(append([]render.Resource{}, p.liveAdmissionPolicies(ctx, cfg, gitOnly, livePolicies)...), live...))
+		p.resolveAdmissionLookups(ctx, cfg, gitOnly, admission, desired, changes)
+		findings = append(findings, admission.EvaluateChanges(desired, changes, cfg.Policy.Profile)...)
+		findings = append(findings, policy.CheckDeprecatedAPIs(desired, kubeVersion)...)
+		if validator := p.schemaValidator(ctx, cfg, gitOnly, kubeVersion, rendered, liveSchemas); validator != nil {
+			findings = append(findings, validator.Validate(desired)...)
+		}
+		findings = policy.ApplyWaivers(cfg.Project, findings, append(append([]render.Resource{}, rendered...), removed...), exceptions, p.exceptionsPath, time.Now())
 		projectPlans = append(projectPlans, report.ProjectPlan{
-			Project:  cfg.Project,
-			Changes:  changes,
-			Summary:  summary,
-			Findings: findings,
+			Project:    cfg.Project,
+			Changes:    changes,
+			Summary:    summary,
+			Findings:   findings,
+			GitOnly:    gitOnly,
+			Suppressed: suppressed,
+			Rollouts:   rolloutImpact(changes, rendered, live),
 		})
 	}
 
@@ -160,6 +288,7 @@ func (p *Planner) PlanForEvent(ctx context.Context, evt MergeRequestEvent) error
 		}
 	}
 
+	p.publishPolicyStatuses(evt, projectPlans)
 	if p.status != nil {
 		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: "thule/plan", State: vcs.CheckSuccess, Description: "Thule plan completed"})
 	}
@@ -172,7 +301,76 @@ func (p *Planner) finishWithError(evt MergeRequestEvent, runID int64, err error)
 	}
 	if p.status != nil {
 		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: "thule/plan", State: vcs.CheckFailed, Description: err.Error()})
+		// Policy cannot pass without a plan to evaluate.
+		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: policyStatusContext, State: vcs.CheckFailed, Description: "Thule plan failed; policy not evaluated"})
+	}
+}
+
+// renderAtBase renders the project as it was at the merge base. A project
+// without a thule.conf there is new in the MR and renders nothing. Charts are
+// templated for kubeVersion like the head render.
+func renderAtBase(baseRoot string, prj project.DiscoveredProject, kubeVersion string) ([]render.Resource, error) {
+	configPath := filepath.Join(baseRoot, prj.ConfigPath)
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, nil
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return render.RenderProjectWithOptions(filepath.Join(baseRoot, prj.Root), cfg, render.Options{RepoRoot: baseRoot, KubeVersion: kubeVersion})
+}
+
+// removedResources returns the resources rendered at the merge base that no
+// longer render at head.
+func removedResources(base, head []render.Resource) []render.Resource {
+	present := map[string]struct{}{}
+	for _, r := range head {
+		present[r.ID()] = struct{}{}
 	}
+	removed := []render.Resource{}
+	for _, r := range base {
+		if _, ok := present[r.ID()]; !ok {
+			removed = append(removed, r)
+		}
+	}
+	return removed
+}
+
+// withoutUnplanned drops the actual objects that are rendered but were
+// filtered out of desired because their sources did not change.
+func withoutUnplanned(actual, rendered, desired []render.Resource) []render.Resource {
+	planned := map[string]struct{}{}
+	for _, r := range desired {
+		planned[r.ID()] = struct{}{}
+	}
+	unplanned := map[string]struct{}{}
+	for _, r := range rendered {
+		if _, ok := planned[r.ID()]; !ok {
+			unplanned[r.ID()] = struct{}{}
+		}
+	}
+	out := make([]render.Resource, 0, len(actual))
+	for _, r := range actual {
+		if _, ok := unplanned[r.ID()]; !ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func selectByID(resources, wanted []render.Resource) []render.Resource {
+	ids := map[string]struct{}{}
+	for _, r := range wanted {
+		ids[r.ID()] = struct{}{}
+	}
+	out := []render.Resource{}
+	for _, r := range resources {
+		if _, ok := ids[r.ID()]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
 }
 
 func filterDesiredByChangedFiles(desired []render.Resource, changedFiles []string, repoRoot string) []render.Resource {
@@ -183,7 +381,7 @@ func filterDesiredByChangedFiles(desired []render.Resource, changedFiles []strin
 	for _, f := range changedFiles {
 		cleaned := filepath.ToSlash(filepath.Clean(strings.TrimSpace(f)))
 		switch strings.ToLower(filepath.Ext(cleaned)) {
-		case ".yaml", ".yml":
+		case ".yaml", ".yml", ".tpl":
 			changedManifestFiles[cleaned] = struct{}{}
 		}
 	}
@@ -193,16 +391,19 @@ func filterDesiredByChangedFiles(desired []render.Resource, changedFiles []strin
 
 	filtered := make([]render.Resource, 0, len(desired))
 	for _, r := range desired {
-		if r.SourcePath == "" {
-			continue
-		}
-		rel, err := filepath.Rel(repoRoot, r.SourcePath)
-		if err != nil {
-			continue
-		}
-		rel = filepath.ToSlash(filepath.Clean(rel))
-		if _, ok := changedManifestFiles[rel]; ok {
-			filtered = append(filtered, r)
+		for _, p := range append([]string{r.SourcePath}, r.InputPaths...) {
+			if p == "" {
+				continue
+			}
+			rel, err := filepath.Rel(repoRoot, p)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(filepath.Clean(rel))
+			if _, ok := changedManifestFiles[rel]; ok {
+				filtered = append(filtered, r)
+				break
+			}
 		}
 	}
 	return filtered
@@ -304,6 +304,16 @@ func TestFilterDesiredByChangedFiles(t *testing.T) {
 		t.Fatalf("unexpected filtered resources: %+v", filtered)
 	}
 
+	chartDesired := []render.Resource{
+		{Name: "deploy", SourcePath: "/repo/apps/p/chart/templates/deploy.yaml", InputPaths: []string{"/repo/apps/p/values.yaml", "/repo/apps/p/chart/templates/_helpers.tpl"}},
+	}
+	if got := filterDesiredByChangedFiles(chartDesired, []string{"apps/p/values.yaml"}, "/repo"); len(got) != 1 {
+		t.Fatalf("expected values file change to select chart resource, got %+v", got)
+	}
+	if got := filterDesiredByChangedFiles(chartDesired, []string{"apps/p/chart/templates/_helpers.tpl"}, "/repo"); len(got) != 1 {
+		t.Fatalf("expected helper template change to select chart resource, got %+v", got)
+	}
+
 	if got := filterDesiredByChangedFiles(desired, []string{"README.md"}, "/repo"); got != nil {
 		t.Fatalf("expected nil result when no yaml files changed, got %+v", got)
 	}
@@ -311,3 +321,512 @@ func TestFilterDesiredByChangedFiles(t *testing.T) {
 		t.Fatalf("expected unchanged resources when changed_files is empty, got %+v", got)
 	}
 }
+
+type fixedBaseTrees struct {
+	dir string
+	err error
+}
+
+func (f fixedBaseTrees) MergeBaseTree(context.Context, string, string) (string, func(), error) {
+	return f.dir, func() {}, f.err
+}
+
+// paymentsConfig is the thule.conf of the apps/payments project most planner
+// tests plan; tests append the sections they need.
+const paymentsConfig = "version: v1\nproject: payments\nclusterRef: prod\nnamespace: payments\nrender:\n  mode: yaml\n  path: manifests\n"
+
+// writePlannerProject writes the apps/payments project under root: its
+// thule.conf and files, by path relative to the project directory.
+func writePlannerProject(t *testing.T, root, cfg string, files map[string]string) {
+	t.Helper()
+	projectDir := filepath.Join(root, "apps", "payments")
+	files["thule.conf"] = cfg
+	for name, content := range files {
+		p := filepath.Join(projectDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPlannerPlansResourcesPatchedByChangedOverlay(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, strings.Replace(paymentsConfig, "mode: yaml\n  path: manifests", "mode: kustomize\n  path: overlays/prod", 1), map[string]string{
+		"base/kustomization.yaml":          "resources:\n  - deploy.yaml\n  - service.yaml\n",
+		"base/deploy.yaml":                 "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n",
+		"base/service.yaml":                "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+		"overlays/prod/kustomization.yaml": "namespace: payments\nresources:\n  - ../../base\npatches:\n  - path: replicas.yaml\n",
+		"overlays/prod/replicas.yaml":      "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n",
+	})
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, &MemoryClusterReader{}, comments, nil, nil, nil)
+	evt := MergeRequestEvent{MergeReqID: 59, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/overlays/prod/replicas.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(59)[0].Body; !strings.Contains(body, "`CREATE` apps/v1|Deployment|payments|web") {
+		t.Fatalf("expected the patched resource in the plan: %s", body)
+	}
+}
+
+func TestPlannerPlansFluxKustomizationPatchedByChangedFile(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, strings.Replace(paymentsConfig, "mode: yaml\n  path: manifests", "mode: flux\n  path: clusters", 1), map[string]string{
+		"clusters/apps.yaml":     "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: apps\n  namespace: payments\nspec:\n  path: ./apps/payments/app\n  sourceRef:\n    kind: GitRepository\n    name: flux-system\n  postBuild:\n    substitute:\n      replicas: \"3\"\n",
+		"app/kustomization.yaml": "resources:\n  - deploy.yaml\npatches:\n  - path: replicas.yaml\n",
+		"app/deploy.yaml":        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  replicas: 1\n",
+		"app/replicas.yaml":      "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  replicas: ${replicas}\n",
+	})
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, &MemoryClusterReader{}, comments, nil, nil, nil)
+	evt := MergeRequestEvent{MergeReqID: 58, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/app/replicas.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(58)[0].Body; !strings.Contains(body, "apps/v1|Deployment|payments|web") {
+		t.Fatalf("expected the resource patched under the Kustomization path in the plan: %s", body)
+	}
+}
+
+func TestPlannerReportsResourcesRemovedSinceMergeBase(t *testing.T) {
+	kept := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: kept\n  namespace: payments\n"
+	gone := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: gone\n  namespace: payments\n"
+	live := []render.Resource{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "payments", Name: "gone", Body: map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]any{"name": "gone", "namespace": "payments"}}}}
+
+	for _, prune := range []string{"true", "false"} {
+		repo := t.TempDir()
+		base := t.TempDir()
+		cfg := paymentsConfig + "diff:\n  prune: " + prune + "\n"
+		writePlannerProject(t, repo, cfg, map[string]string{"manifests/a.yaml": kept})
+		writePlannerProject(t, base, cfg, map[string]string{"manifests/a.yaml": kept, "manifests/b.yaml": gone})
+
+		cluster := &MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": live}}
+		comments := vcs.NewMemoryCommentStore()
+		planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+		planner.SetBaseTrees(fixedBaseTrees{dir: base})
+
+		evt := MergeRequestEvent{MergeReqID: 60, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/b.yaml"}}
+		if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+			t.Fatalf("plan failed: %v", err)
+		}
+		body := comments.List(60)[0].Body
+		deleted := strings.Contains(body, "`DELETE` v1|ConfigMap|payments|gone")
+		if prune == "true" && !deleted {
+			t.Fatalf("expected removed manifest as DELETE: %s", body)
+		}
+		if prune == "false" && deleted {
+			t.Fatalf("did not expect DELETE without diff.prune: %s", body)
+		}
+		if strings.Contains(body, "kept") {
+			t.Fatalf("did not expect untouched resource in plan comment: %s", body)
+		}
+	}
+}
+
+func TestPlannerIgnoresUnavailableMergeBase(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{"manifests/a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: payments\n"})
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, &MemoryClusterReader{}, comments, nil, nil, nil)
+	planner.SetBaseTrees(fixedBaseTrees{err: fmt.Errorf("no clone")})
+	evt := MergeRequestEvent{MergeReqID: 61, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/a.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(61)[0].Body; !strings.Contains(body, "`CREATE` v1|ConfigMap|payments|a") {
+		t.Fatalf("expected head plan without merge base: %s", body)
+	}
+}
+
+func TestPlannerGitOnlyDiffsAgainstMergeBase(t *testing.T) {
+	cfg := func(mode string) string {
+		return strings.Replace(paymentsConfig, "clusterRef: prod", "clusterRef: airgapped", 1) + "diff:\n  prune: true\n  mode: " + mode + "\n"
+	}
+	cm := func(name, value string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: payments\ndata:\n  key: " + value + "\n"
+	}
+	for _, tc := range []struct {
+		name, mode string
+		clusters   []string
+	}{
+		{name: "project mode", mode: "git-only"},
+		{name: "cluster list", mode: "live", clusters: []string{"airgapped"}},
+	} {
+		repo := t.TempDir()
+		base := t.TempDir()
+		writePlannerProject(t, repo, cfg(tc.mode), map[string]string{"manifests/a.yaml": cm("changed", "new"), "manifests/c.yaml": cm("added", "x"), "manifests/d.yaml": cm("untouched", "x")})
+		writePlannerProject(t, base, cfg("live"), map[string]string{"manifests/a.yaml": cm("changed", "old"), "manifests/b.yaml": cm("gone", "x"), "manifests/d.yaml": cm("untouched", "x")})
+
+		comments := vcs.NewMemoryCommentStore()
+		planner := NewPlanner(repo, &errCluster{}, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+		planner.SetBaseTrees(fixedBaseTrees{dir: base})
+		planner.SetGitOnlyClusters(tc.clusters)
+		evt := MergeRequestEvent{MergeReqID: 70, HeadSHA: "abc", ChangedFiles: []string{
+			"apps/payments/manifests/a.yaml", "apps/payments/manifests/b.yaml", "apps/payments/manifests/c.yaml",
+		}}
+		if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+			t.Fatalf("%s: plan failed: %v", tc.name, err)
+		}
+		body := comments.List(70)[0].Body
+		for _, want := range []string{
+			"Repository-only diff",
+			"Summary: CREATE=1 PATCH=1 DELETE=1",
+			"`PATCH` v1|ConfigMap|payments|changed",
+			"`CREATE` v1|ConfigMap|payments|added",
+			"`DELETE` v1|ConfigMap|payments|gone",
+		} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("%s: expected %q in comment: %s", tc.name, want, body)
+			}
+		}
+		if strings.Contains(body, "untouched") {
+			t.Fatalf("%s: did not expect untouched resource: %s", tc.name, body)
+		}
+	}
+}
+
+func TestPlannerGitOnlyFailsWithoutMergeBase(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig+"diff:\n  mode: git-only\n", map[string]string{"manifests/a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: payments\n"})
+	statuses := vcs.NewMemoryStatusPublisher()
+	planner := NewPlanner(repo, &MemoryClusterReader{}, vcs.NewMemoryCommentStore(), statuses, nil, nil)
+	planner.SetBaseTrees(fixedBaseTrees{err: fmt.Errorf("no clone")})
+	evt := MergeRequestEvent{MergeReqID: 71, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/a.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err == nil || !strings.Contains(err.Error(), "no clone") {
+		t.Fatalf("expected merge base error, got %v", err)
+	}
+	got := statuses.ListStatuses(71, "abc")
+	if got[len(got)-1].State != vcs.CheckFailed {
+		t.Fatalf("expected failed status, got %+v", got)
+	}
+}
+
+func TestPlannerSeparatesDriftFromMergeBase(t *testing.T) {
+	cm := func(value string) map[string]string {
+		return map[string]string{"manifests/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: payments\ndata:\n  mr: " + value + "\n  drift: x\n"}
+	}
+	repo := t.TempDir()
+	base := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, cm("new"))
+	writePlannerProject(t, base, paymentsConfig, cm("old"))
+	live := render.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "payments", Name: "cm", Body: map[string]any{
+		"apiVersion": "v1", "kind": "ConfigMap",
+		"metadata": map[string]any{"name": "cm", "namespace": "payments"},
+		"data":     map[string]any{"mr": "old", "drift": "hand-edited"},
+	}}
+	cluster := &MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {live}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	planner.SetBaseTrees(fixedBaseTrees{dir: base})
+	evt := MergeRequestEvent{MergeReqID: 72, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/cm.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(72)[0].Body; !strings.Contains(body, "drift=[data.drift]") {
+		t.Fatalf("expected drift paths separated from MR changes: %s", body)
+	}
+}
+
+func TestPlannerReportsSchemaErrors(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig+"kubeVersion: v1.29.4\n", map[string]string{
+		"manifests/pod.yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  containers:\n  - name: web\n    image: web:1\n    imagePullPolicy: Allways\n",
+	})
+	cluster := &MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 73, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/pod.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(73)[0].Body; !strings.Contains(body, "schema-validation") || !strings.Contains(body, "spec.containers[0].imagePullPolicy") {
+		t.Fatalf("expected schema finding in comment: %s", body)
+	}
+}
+
+type versionedCluster struct {
+	MemoryClusterReader
+	version string
+}
+
+func (v *versionedCluster) ServerVersion(context.Context, string, string) (string, error) {
+	return v.version, nil
+}
+
+func TestPlannerFlagsAPIsRemovedInClusterVersion(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{
+		"manifests/pdb.yaml": "apiVersion: policy/v1beta1\nkind: PodDisruptionBudget\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  minAvailable: 1\n",
+	})
+	cluster := &versionedCluster{MemoryClusterReader: MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {}}}, version: "v1.27.3-gke.100"}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 74, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/pdb.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(74)[0].Body; !strings.Contains(body, "removed-api") || !strings.Contains(body, "migrate to policy/v1") {
+		t.Fatalf("expected removed API finding in comment: %s", body)
+	}
+}
+
+func TestPlannerTemplatesChartsForLiveClusterVersion(t *testing.T) {
+	repo := t.TempDir()
+	cfg := strings.Replace(paymentsConfig, "mode: yaml\n  path: manifests", "mode: helm\n  path: chart", 1)
+	writePlannerProject(t, repo, cfg+"kubeVersion: v1.29.4\n", map[string]string{
+		"chart/Chart.yaml":            "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"chart/templates/config.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: payments\ndata:\n  kube: {{ .Capabilities.KubeVersion.Version | quote }}\n",
+	})
+	cluster := &versionedCluster{MemoryClusterReader: MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {}}}, version: "v1.30.2"}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 82, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/chart/templates/config.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(82)[0].Body; !strings.Contains(body, "kube: v1.30.2") {
+		t.Fatalf("expected the chart templated for the cluster's version: %s", body)
+	}
+}
+
+func TestPlannerWaivesFindingsFromExceptionsFile(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{
+		"manifests/pod.yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  containers:\n  - name: web\n    image: web:latest\n",
+	})
+	exceptions := "exceptions:\n- rule: latest-image-tag\n  selector:\n    project: payments\n    kind: Pod\n  reason: preview environment\n"
+	if err := os.WriteFile(filepath.Join(repo, "exceptions.yaml"), []byte(exceptions), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), policy.NewBuiltinEvaluator())
+	planner.SetPolicyExceptions("exceptions.yaml")
+	evt := MergeRequestEvent{MergeReqID: 75, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/pod.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	body := comments.List(75)[0].Body
+	if !strings.Contains(body, "<details><summary>Waived (1 findings)</summary>") || !strings.Contains(body, "waived by exceptions.yaml: preview environment") {
+		t.Fatalf("expected waived finding in comment: %s", body)
+	}
+}
+
+func TestPlannerGatesOnUnwaivedPolicyErrors(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig+"policy:\n  profile: baseline\n", map[string]string{
+		"manifests/pod.yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  containers:\n  - name: web\n    image: web:1\n    securityContext:\n      privileged: true\n",
+	})
+	if err := os.WriteFile(filepath.Join(repo, "exceptions.yaml"), []byte("exceptions:\n- rule: privileged-container\n  selector: {name: web}\n  reason: debugging\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {}}}
+	status := vcs.NewMemoryStatusPublisher()
+	planner := NewPlanner(repo, cluster, vcs.NewMemoryCommentStore(), status, run.NewMemoryStore(), policy.NewBuiltinEvaluator())
+	latest := func(mr int64) map[string]vcs.StatusCheck {
+		out := map[string]vcs.StatusCheck{}
+		for _, s := range status.ListStatuses(mr, "abc") {
+			out[s.Context] = s
+		}
+		return out
+	}
+
+	evt := MergeRequestEvent{MergeReqID: 76, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/pod.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	statuses := latest(76)
+	if statuses["thule/plan"].State != vcs.CheckSuccess {
+		t.Fatalf("plan status should succeed: %+v", statuses)
+	}
+	for _, name := range []string{"thule/policy", "thule/policy/payments"} {
+		if s := statuses[name]; s.State != vcs.CheckFailed || !strings.HasPrefix(s.Description, "1 unwaived policy errors") {
+			t.Fatalf("expected failed %s status, got %+v", name, s)
+		}
+	}
+
+	planner.SetPolicyExceptions("exceptions.yaml")
+	evt.MergeReqID = 77
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	statuses = latest(77)
+	for _, name := range []string{"thule/policy", "thule/policy/payments"} {
+		if s := statuses[name]; s.State != vcs.CheckSuccess || s.Description != "No unwaived policy errors (0 warnings, 1 waived)" {
+			t.Fatalf("expected successful %s status, got %+v", name, s)
+		}
+	}
+}
+
+// ownedObjectsCluster returns every object of its namespace, like the live
+// reader does for Flux-owned objects of changed templates.
+type ownedObjectsCluster struct {
+	MemoryClusterReader
+}
+
+func (o *ownedObjectsCluster) ListResourcesWithProject(ctx context.Context, _, clusterRef, namespace string, _ []render.Resource) ([]render.Resource, error) {
+	return o.ListResources(ctx, clusterRef, namespace)
+}
+
+func TestPlannerDoesNotDeleteUnchangedOwnedSiblings(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig+"diff:\n  prune: true\n", map[string]string{
+		"manifests/config.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: payments\ndata:\n  mode: new\n",
+		"manifests/pvc.yaml":    "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: data\n  namespace: payments\n",
+	})
+	owned := func(kind, name string, body map[string]any) render.Resource {
+		body["apiVersion"], body["kind"] = "v1", kind
+		body["metadata"] = map[string]any{"name": name, "namespace": "payments", "labels": map[string]any{render.HelmReleaseNameLabel: "payments"}}
+		return render.Resource{APIVersion: "v1", Kind: kind, Namespace: "payments", Name: name, Body: body}
+	}
+	cluster := &ownedObjectsCluster{MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {
+		owned("ConfigMap", "settings", map[string]any{"data": map[string]any{"mode": "old"}}),
+		owned("PersistentVolumeClaim", "data", map[string]any{}),
+		owned("ConfigMap", "dropped", map[string]any{}),
+	}}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), policy.NewBuiltinEvaluator())
+	evt := MergeRequestEvent{MergeReqID: 78, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/config.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	body := comments.List(78)[0].Body
+	if !strings.Contains(body, "`PATCH` v1|ConfigMap|payments|settings") || !strings.Contains(body, "`DELETE` v1|ConfigMap|payments|dropped") {
+		t.Fatalf("expected the changed and dropped objects in the plan: %s", body)
+	}
+	if strings.Contains(body, "PersistentVolumeClaim|payments|data") {
+		t.Fatalf("unchanged sibling should not be deleted: %s", body)
+	}
+}
+
+// objectCluster gets the wanted objects by ID from every namespace, like the
+// live reader does, and serves its cluster-scoped objects as the installed
+// admission policies.
+type objectCluster struct {
+	MemoryClusterReader
+}
+
+func (o *objectCluster) ListAdmissionPolicies(ctx context.Context, _, clusterRef string) ([]render.Resource, error) {
+	return o.ListResources(ctx, clusterRef, "")
+}
+
+func (o *objectCluster) ListResourcesWithProject(_ context.Context, _, clusterRef, _ string, wanted []render.Resource) ([]render.Resource, error) {
+	out := []render.Resource{}
+	for key, items := range o.ByClusterNS {
+		if !strings.HasPrefix(key, clusterRef+"/") {
+			continue
+		}
+		for _, r := range items {
+			for _, w := range wanted {
+				if r.ID() == w.ID() {
+					out = append(out, r)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestPlannerAdmitsWithParamsAndNamespacesFromCluster(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{
+		"manifests/deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n  namespace: payments\nspec:\n  replicas: 8\n",
+	})
+	admission := func(kind string, spec map[string]any) render.Resource {
+		return render.Resource{APIVersion: "admissionregistration.k8s.io/v1", Kind: kind, Name: "replica-limit", Body: map[string]any{"spec": spec}}
+	}
+	cluster := &objectCluster{MemoryClusterReader{ByClusterNS: map[string][]render.Resource{
+		"prod/": {
+			admission("ValidatingAdmissionPolicy", map[string]any{
+				"paramKind": map[string]any{"apiVersion": "v1", "kind": "ConfigMap"},
+				"matchConstraints": map[string]any{"resourceRules": []any{map[string]any{
+					"apiGroups": []any{"apps"}, "apiVersions": []any{"v1"}, "resources": []any{"deployments"}, "operations": []any{"CREATE"},
+				}}},
+				"validations": []any{map[string]any{"expression": "object.spec.replicas <= int(params.data.maxReplicas)", "message": "too many replicas"}},
+			}),
+			admission("ValidatingAdmissionPolicyBinding", map[string]any{
+				"policyName":        "replica-limit",
+				"validationActions": []any{"Deny"},
+				"paramRef":          map[string]any{"name": "limits", "namespace": "platform", "parameterNotFoundAction": "Deny"},
+				"matchResources":    map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"env": "prod"}}},
+			}),
+			{APIVersion: "v1", Kind: "Namespace", Name: "payments", Body: map[string]any{"metadata": map[string]any{"name": "payments", "labels": map[string]any{"env": "prod"}}}},
+		},
+		"prod/platform": {{APIVersion: "v1", Kind: "ConfigMap", Namespace: "platform", Name: "limits", Body: map[string]any{"data": map[string]any{"maxReplicas": "5"}}}},
+	}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 79, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/deploy.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(79)[0].Body; !strings.Contains(body, "vap/replica-limit") || !strings.Contains(body, "rejects create: too many replicas") {
+		t.Fatalf("expected the live-only param and Namespace to be used: %s", body)
+	}
+}
+
+// autoscaledCluster lists the autoscalers of an objectCluster's namespaces.
+type autoscaledCluster struct {
+	objectCluster
+}
+
+func (a *autoscaledCluster) ListAutoscalers(ctx context.Context, _, clusterRef string, namespaces []string) ([]render.Resource, error) {
+	out := []render.Resource{}
+	for _, ns := range namespaces {
+		items, _ := a.ListResources(ctx, clusterRef, ns)
+		for _, r := range items {
+			if _, ok := autoscalers[r.Kind]; ok {
+				out = append(out, r)
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestPlannerSuppressesReplicasScaledByLiveOnlyAutoscaler(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{
+		"manifests/deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: payments\nspec:\n  replicas: 2\n",
+	})
+	cluster := &autoscaledCluster{objectCluster{MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "payments", Name: "web", Body: map[string]any{
+			"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "web", "namespace": "payments"}, "spec": map[string]any{"replicas": 7},
+		}},
+		{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Namespace: "payments", Name: "web", Body: map[string]any{
+			"spec": map[string]any{"scaleTargetRef": map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web"}},
+		}},
+	}}}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 80, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/deploy.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	body := comments.List(80)[0].Body
+	if !strings.Contains(body, "scaled by HorizontalPodAutoscaler `payments/web`") || strings.Contains(body, "`PATCH` apps/v1|Deployment|payments|web") {
+		t.Fatalf("expected replicas scaled by the live HPA to be left out of the diff: %s", body)
+	}
+}
+
+func TestPlannerFindsLiveReferencesOfManifestsWithoutNamespace(t *testing.T) {
+	repo := t.TempDir()
+	writePlannerProject(t, repo, paymentsConfig, map[string]string{
+		"manifests/deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: web\n        image: web:1\n        envFrom:\n        - secretRef:\n            name: db\n        - configMapRef:\n            name: settings\n",
+	})
+	cluster := &objectCluster{MemoryClusterReader{ByClusterNS: map[string][]render.Resource{"prod/payments": {
+		{APIVersion: "v1", Kind: "Secret", Namespace: "payments", Name: "db"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "payments", Name: "settings"},
+	}}}}
+	comments := vcs.NewMemoryCommentStore()
+	planner := NewPlanner(repo, cluster, comments, vcs.NewMemoryStatusPublisher(), run.NewMemoryStore(), nil)
+	evt := MergeRequestEvent{MergeReqID: 81, HeadSHA: "abc", ChangedFiles: []string{"apps/payments/manifests/deploy.yaml"}}
+	if err := planner.PlanForEvent(context.Background(), evt); err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if body := comments.List(81)[0].Body; strings.Contains(body, "dangling-reference") {
+		t.Fatalf("expected the live Secret and ConfigMap to resolve: %s", body)
+	}
+}
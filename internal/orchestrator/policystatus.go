@@ -0,0 +1,48 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/example/thule/internal/policy"
+	"github.com/example/thule/internal/report"
+	"github.com/example/thule/internal/vcs"
+)
+
+// policyStatusContext is the commit status gating merges on policy; each
+// project also gets "<policyStatusContext>/<project>".
+const policyStatusContext = "thule/policy"
+
+// publishPolicyStatuses fails the policy statuses of projects with unwaived
+// ERROR findings, and the overall status when any project fails.
+func (p *Planner) publishPolicyStatuses(evt MergeRequestEvent, plans []report.ProjectPlan) {
+	if p.status == nil {
+		return
+	}
+	all := []policy.Finding{}
+	for _, plan := range plans {
+		state, description := policyStatus(plan.Findings)
+		p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: policyStatusContext + "/" + plan.Project, State: state, Description: description})
+		all = append(all, plan.Findings...)
+	}
+	state, description := policyStatus(all)
+	p.status.SetStatus(vcs.StatusCheck{MergeReqID: evt.MergeReqID, SHA: evt.HeadSHA, Context: policyStatusContext, State: state, Description: description})
+}
+
+func policyStatus(findings []policy.Finding) (vcs.CheckState, string) {
+	errors, warnings, waived := 0, 0, 0
+	for _, f := range findings {
+		switch {
+		case f.Waiver != nil:
+			waived++
+		case f.Severity == policy.SeverityError:
+			errors++
+		default:
+			warnings++
+		}
+	}
+	counts := fmt.Sprintf("%d warnings, %d waived", warnings, waived)
+	if errors > 0 {
+		return vcs.CheckFailed, fmt.Sprintf("%d unwaived policy errors (%s)", errors, counts)
+	}
+	return vcs.CheckSuccess, fmt.Sprintf("No unwaived policy errors (%s)", counts)
+}
@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	"github.com/example/thule/internal/render"
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+// liveReferenceLookup resolves the objects workloads in desired reference
+// but the render does not declare against the cluster. It returns nil when the
+// cluster cannot be asked, which disables dangling-reference findings.
+func (p *Planner) liveReferenceLookup(ctx context.Context, cfg thuleconfig.Config, desired, rendered, actual []render.Resource) func(render.Reference) bool {
+	declared := map[string]struct{}{}
+	for _, r := range rendered {
+		declared[r.ID()] = struct{}{}
+	}
+	// References of manifests without a namespace land in the project's,
+	// where the cluster reader looks them up.
+	inNamespace := func(ref render.Reference) render.Reference {
+		if ref.Namespace == "" && cfg.Namespace != "all" {
+			ref.Namespace = cfg.Namespace
+		}
+		return ref
+	}
+	wanted := []render.Resource{}
+	for _, r := range desired {
+		for _, ref := range r.References() {
+			if _, ok := declared[ref.ID()]; ok {
+				continue
+			}
+			ref = inNamespace(ref)
+			if _, ok := declared[ref.ID()]; ok {
+				continue
+			}
+			declared[ref.ID()] = struct{}{}
+			wanted = append(wanted, render.Resource{APIVersion: "v1", Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+		}
+	}
+	found := actual
+	if projectAware, ok := p.cluster.(ProjectAwareClusterReader); ok && len(wanted) > 0 {
+		var err error
+		found, err = projectAware.ListResourcesWithProject(ctx, cfg.Project, cfg.ClusterRef, cfg.Namespace, wanted)
+		if err != nil {
+			log.Printf("reference lookup failed project=%s err=%v", cfg.Project, err)
+			return nil
+		}
+	}
+	existing := map[string]struct{}{}
+	for _, r := range found {
+		existing[r.ID()] = struct{}{}
+	}
+	return func(ref render.Reference) bool {
+		_, ok := existing[inNamespace(ref).ID()]
+		return ok
+	}
+}
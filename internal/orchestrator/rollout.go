@@ -0,0 +1,185 @@
+package orchestrator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+	"github.com/example/thule/internal/report"
+)
+
+// rolloutKinds are the workloads whose pods roll on pod template changes.
+var rolloutKinds = map[string]struct{}{
+	"Deployment":  {},
+	"StatefulSet": {},
+	"DaemonSet":   {},
+}
+
+// generatedNameHash matches the content hash kustomize generators append to
+// ConfigMap and Secret names.
+var generatedNameHash = regexp.MustCompile(`-[a-z0-9]{10}$`)
+
+// rolloutImpact estimates which workloads restart because of the planned
+// changes and how many pods that touches, using live replica counts. It also
+// lists workloads consuming a ConfigMap or Secret changed in place, which do
+// not restart on their own.
+func rolloutImpact(changes []diff.Change, rendered, live []render.Resource) []report.RolloutImpact {
+	desiredByID := map[string]render.Resource{}
+	for _, r := range rendered {
+		desiredByID[r.ID()] = r
+	}
+	liveByID := map[string]render.Resource{}
+	for _, r := range live {
+		liveByID[r.ID()] = r
+	}
+	changedConfigs := map[string]struct{}{}
+	for _, c := range changes {
+		parts := strings.Split(c.ID, "|")
+		if c.Action == diff.Patch && len(parts) == 4 && (parts[1] == "ConfigMap" || parts[1] == "Secret") {
+			changedConfigs[objectKey(parts[1], parts[2], parts[3])] = struct{}{}
+		}
+	}
+
+	impacts := map[string]*report.RolloutImpact{}
+	for _, c := range changes {
+		d := desiredByID[c.ID]
+		if _, workload := rolloutKinds[d.Kind]; !workload || c.Action != diff.Patch || !templateChanged(c.ChangedPaths) {
+			continue
+		}
+		a := liveByID[c.ID]
+		impacts[c.ID] = &report.RolloutImpact{
+			Workload: c.ID,
+			Restart:  true,
+			Pods:     podCount(a, d),
+			Reasons:  templateChangeReasons(d, a),
+		}
+	}
+	for _, d := range rendered {
+		if _, workload := rolloutKinds[d.Kind]; !workload {
+			continue
+		}
+		if impact, ok := impacts[d.ID()]; ok && impact.Restart {
+			continue
+		}
+		seen := map[string]struct{}{}
+		for _, ref := range d.References() {
+			// Pull secrets only matter when images are pulled again.
+			if _, changed := changedConfigs[objectKey(ref.Kind, ref.Namespace, ref.Name)]; !changed || ref.Via == "imagePullSecrets" {
+				continue
+			}
+			reason := fmt.Sprintf("references changed %s `%s` via %s; running pods keep the old values until restarted", ref.Kind, ref.Name, ref.Via)
+			if strings.HasPrefix(ref.Via, "volume") {
+				reason = fmt.Sprintf("mounts changed %s `%s` (%s); files update in place without a restart", ref.Kind, ref.Name, ref.Via)
+			}
+			if _, dup := seen[reason]; dup {
+				continue
+			}
+			seen[reason] = struct{}{}
+			impact, ok := impacts[d.ID()]
+			if !ok {
+				impact = &report.RolloutImpact{Workload: d.ID(), Pods: podCount(liveByID[d.ID()], d)}
+				impacts[d.ID()] = impact
+			}
+			impact.Reasons = append(impact.Reasons, reason)
+		}
+	}
+
+	out := make([]report.RolloutImpact, 0, len(impacts))
+	for _, impact := range impacts {
+		out = append(out, *impact)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Workload < out[j].Workload })
+	return out
+}
+
+func templateChanged(paths []string) bool {
+	for _, p := range paths {
+		if p == "spec.template" || strings.HasPrefix(p, "spec.template.") {
+			return true
+		}
+	}
+	return false
+}
+
+// templateChangeReasons names generated ConfigMaps and Secrets whose hashed
+// name changed, falling back to a plain pod template change.
+func templateChangeReasons(desired, actual render.Resource) []string {
+	liveRefs := map[string][]string{}
+	for _, ref := range actual.References() {
+		base := ref.Kind + "|" + generatedNameHash.ReplaceAllString(ref.Name, "")
+		liveRefs[base] = append(liveRefs[base], ref.Name)
+	}
+	reasons := []string{}
+	seen := map[string]struct{}{}
+	for _, ref := range desired.References() {
+		if !generatedNameHash.MatchString(ref.Name) {
+			continue
+		}
+		for _, old := range liveRefs[ref.Kind+"|"+generatedNameHash.ReplaceAllString(ref.Name, "")] {
+			if old == ref.Name {
+				continue
+			}
+			reason := fmt.Sprintf("generated %s `%s` replaced by `%s`", ref.Kind, old, ref.Name)
+			if _, dup := seen[reason]; !dup {
+				seen[reason] = struct{}{}
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "pod template changed")
+	}
+	return reasons
+}
+
+// podCount prefers the live replica count and falls back to the desired one;
+// -1 means unknown.
+func podCount(live, desired render.Resource) int {
+	for _, body := range []map[string]any{live.Body, desired.Body} {
+		if body == nil {
+			continue
+		}
+		status, _ := body["status"].(map[string]any)
+		if live.Kind == "DaemonSet" || desired.Kind == "DaemonSet" {
+			if n, ok := asInt(status["desiredNumberScheduled"]); ok {
+				return n
+			}
+			continue
+		}
+		spec, _ := body["spec"].(map[string]any)
+		if n, ok := asInt(spec["replicas"]); ok {
+			return n
+		}
+		if n, ok := asInt(status["replicas"]); ok {
+			return n
+		}
+	}
+	if desired.Kind == "DaemonSet" {
+		return -1
+	}
+	// Deployments and StatefulSets default to one replica.
+	return 1
+}
+
+// asInt reads a count decoded from YAML, JSON or the API server.
+func asInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+func objectKey(kind, namespace, name string) string {
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	return kind + "|" + namespace + "|" + name
+}
@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+func TestRolloutImpact(t *testing.T) {
+	workload := func(kind, name string, volumes, env []any, replicas any) render.Resource {
+		spec := map[string]any{"template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "app", "env": env}},
+			"volumes":    volumes,
+		}}}
+		if replicas != nil {
+			spec["replicas"] = replicas
+		}
+		return render.Resource{APIVersion: "apps/v1", Kind: kind, Namespace: "n", Name: name, Body: map[string]any{"spec": spec}}
+	}
+	configVolume := func(name string) []any {
+		return []any{map[string]any{"name": "config", "configMap": map[string]any{"name": name}}}
+	}
+	secretEnv := []any{map[string]any{"name": "TOKEN", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "token", "key": "t"}}}}
+
+	rendered := []render.Resource{
+		workload("Deployment", "web", configVolume("web-config-7h2kt5m9bf"), nil, 2),
+		workload("StatefulSet", "db", nil, secretEnv, 3),
+		workload("Deployment", "files", configVolume("settings"), nil, nil),
+		workload("Deployment", "idle", nil, nil, 1),
+	}
+	live := []render.Resource{
+		workload("Deployment", "web", configVolume("web-config-5g8d2tk4fm"), nil, int64(6)),
+		workload("StatefulSet", "db", nil, secretEnv, int64(3)),
+		workload("Deployment", "files", configVolume("settings"), nil, int64(4)),
+	}
+	changes := []diff.Change{
+		{ID: "apps/v1|Deployment|n|web", Action: diff.Patch, ChangedPaths: []string{"spec.template.spec.volumes[name=config].configMap.name"}},
+		{ID: "v1|Secret|n|token", Action: diff.Patch, ChangedPaths: []string{"data.t"}},
+		{ID: "v1|ConfigMap|n|settings", Action: diff.Patch, ChangedPaths: []string{"data.a"}},
+		{ID: "apps/v1|Deployment|n|idle", Action: diff.Patch, ChangedPaths: []string{"metadata.labels.a"}},
+	}
+
+	impacts := rolloutImpact(changes, rendered, live)
+	if len(impacts) != 3 {
+		t.Fatalf("expected three affected workloads, got %+v", impacts)
+	}
+	byName := map[string]int{}
+	for i, impact := range impacts {
+		byName[impact.Workload[strings.LastIndex(impact.Workload, "|")+1:]] = i
+	}
+	web := impacts[byName["web"]]
+	if !web.Restart || web.Pods != 6 || len(web.Reasons) != 1 || !strings.Contains(web.Reasons[0], "`web-config-5g8d2tk4fm` replaced by `web-config-7h2kt5m9bf`") {
+		t.Fatalf("unexpected web impact %+v", web)
+	}
+	db := impacts[byName["db"]]
+	if db.Restart || db.Pods != 3 || !strings.Contains(db.Reasons[0], "Secret `token` via env") {
+		t.Fatalf("unexpected db impact %+v", db)
+	}
+	files := impacts[byName["files"]]
+	if files.Restart || files.Pods != 4 || !strings.Contains(files.Reasons[0], "files update in place") {
+		t.Fatalf("unexpected files impact %+v", files)
+	}
+}
@@ -0,0 +1,49 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+
+	"github.com/example/thule/internal/render"
+	"github.com/example/thule/internal/schema"
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+// SchemaReader is implemented by cluster readers that can fetch the OpenAPI
+// document a cluster serves.
+type SchemaReader interface {
+	OpenAPISchema(ctx context.Context, projectID, clusterRef string) (*openapi_v2.Document, error)
+}
+
+// schemaValidator picks the schemas rendered resources are validated
+// against: the live cluster's OpenAPI document when it can be fetched,
+// otherwise the bundled schemas closest to kubeVersion (see schema.Bundled;
+// they may be older, in which case unknown fields are only warnings). CRDs
+// rendered from the repo are added on top. Live validators are cached per
+// clusterRef in cache for the duration of a plan.
+func (p *Planner) schemaValidator(ctx context.Context, cfg thuleconfig.Config, gitOnly bool, kubeVersion string, rendered []render.Resource, cache map[string]*schema.Validator) *schema.Validator {
+	v, ok := cache[cfg.ClusterRef]
+	if !ok && !gitOnly {
+		if reader, isReader := p.cluster.(SchemaReader); isReader {
+			doc, err := reader.OpenAPISchema(ctx, cfg.Project, cfg.ClusterRef)
+			if err == nil {
+				v, err = schema.FromDocument(doc)
+			}
+			if err != nil {
+				log.Printf("live openapi schema unavailable project=%s cluster=%s err=%v", cfg.Project, cfg.ClusterRef, err)
+				v = nil
+			}
+			cache[cfg.ClusterRef] = v
+		}
+	}
+	if v == nil {
+		var err error
+		if v, err = schema.Bundled(kubeVersion); err != nil {
+			log.Printf("bundled openapi schema unavailable project=%s err=%v", cfg.Project, err)
+			return nil
+		}
+	}
+	return v.WithCRDs(rendered)
+}
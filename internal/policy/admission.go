@@ -0,0 +1,524 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/cel/environment"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+const admissionGroup = "admissionregistration.k8s.io"
+
+// irregularResources are kinds whose resource name is not the regular plural.
+var irregularResources = map[string]string{
+	"Endpoints":                 "endpoints",
+	"PodSecurityPolicy":         "podsecuritypolicies",
+	"ComponentStatus":           "componentstatuses",
+	"NetworkPolicy":             "networkpolicies",
+	"ResourceQuota":             "resourcequotas",
+	"ValidatingAdmissionPolicy": "validatingadmissionpolicies",
+}
+
+// AdmissionEvaluator evaluates ValidatingAdmissionPolicies and their bindings
+// offline with CEL, so manifests the cluster would reject at admission are
+// reported at plan time. Policies declared in the render take precedence over
+// live ones with the same name. Deny bindings produce ERROR findings, Warn and
+// Audit bindings WARN; expressions that cannot be evaluated are reported per
+// the policy's failurePolicy.
+//
+// Admission requests are derived from the planned changes: CREATE, UPDATE
+// (with oldObject from the live state) or DELETE. The authorizer variable is
+// not available offline, and namespaces without a Namespace object in the
+// render or live state match namespaceSelectors as if unlabelled. Params and
+// Namespaces only the cluster holds are fetched through Lookups and Resolve;
+// until then a missing param is only a warning.
+type AdmissionEvaluator struct {
+	policies map[string]admissionPolicy
+	bindings []admissionBinding
+	declared map[string]render.Resource
+	live     map[string]render.Resource
+	programs map[string]cel.Program
+	resolved bool
+}
+
+type admissionPolicy struct {
+	Name string
+	Spec struct {
+		FailurePolicy    string            `json:"failurePolicy"`
+		ParamKind        *admissionKind    `json:"paramKind"`
+		MatchConstraints *matchResources   `json:"matchConstraints"`
+		MatchConditions  []namedExpression `json:"matchConditions"`
+		Variables        []namedExpression `json:"variables"`
+		Validations      []struct {
+			Expression        string `json:"expression"`
+			Message           string `json:"message"`
+			MessageExpression string `json:"messageExpression"`
+		} `json:"validations"`
+	} `json:"spec"`
+}
+
+type admissionBinding struct {
+	Name string
+	Spec struct {
+		PolicyName        string          `json:"policyName"`
+		ValidationActions []string        `json:"validationActions"`
+		MatchResources    *matchResources `json:"matchResources"`
+		ParamRef          *struct {
+			Name                    string `json:"name"`
+			Namespace               string `json:"namespace"`
+			ParameterNotFoundAction string `json:"parameterNotFoundAction"`
+		} `json:"paramRef"`
+	} `json:"spec"`
+}
+
+type admissionKind struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+type namedExpression struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+type matchResources struct {
+	NamespaceSelector    *metav1.LabelSelector `json:"namespaceSelector"`
+	ObjectSelector       *metav1.LabelSelector `json:"objectSelector"`
+	ResourceRules        []resourceRule        `json:"resourceRules"`
+	ExcludeResourceRules []resourceRule        `json:"excludeResourceRules"`
+}
+
+type resourceRule struct {
+	APIGroups     []string `json:"apiGroups"`
+	APIVersions   []string `json:"apiVersions"`
+	Resources     []string `json:"resources"`
+	Operations    []string `json:"operations"`
+	ResourceNames []string `json:"resourceNames"`
+	Scope         string   `json:"scope"`
+}
+
+// admissionRequest is one object under admission.
+type admissionRequest struct {
+	id        string
+	operation string
+	resource  render.Resource
+	object    map[string]any
+	oldObject map[string]any
+}
+
+// NewAdmissionEvaluator reads policies and bindings from declared (the
+// render) and live (cluster state). Both also serve as the source of oldObject
+// values, policy params and Namespace labels.
+func NewAdmissionEvaluator(declared, live []render.Resource) *AdmissionEvaluator {
+	e := &AdmissionEvaluator{policies: map[string]admissionPolicy{}, declared: map[string]render.Resource{}, live: map[string]render.Resource{}, programs: map[string]cel.Program{}}
+	bindings := map[string]admissionBinding{}
+	for _, set := range []struct {
+		resources []render.Resource
+		index     map[string]render.Resource
+	}{{live, e.live}, {declared, e.declared}} {
+		for _, r := range set.resources {
+			set.index[r.ID()] = r
+			if !strings.HasPrefix(r.APIVersion, admissionGroup+"/") {
+				continue
+			}
+			switch r.Kind {
+			case "ValidatingAdmissionPolicy":
+				var p admissionPolicy
+				if decodeBody(r.Body, &p) == nil {
+					p.Name = r.Name
+					e.policies[r.Name] = p
+				}
+			case "ValidatingAdmissionPolicyBinding":
+				var b admissionBinding
+				if decodeBody(r.Body, &b) == nil {
+					b.Name = r.Name
+					bindings[r.Name] = b
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(bindings))
+	for n := range bindings {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		e.bindings = append(e.bindings, bindings[n])
+	}
+	return e
+}
+
+func (e *AdmissionEvaluator) Evaluate(resources []render.Resource, profile string) []Finding {
+	return e.EvaluateChanges(resources, nil, profile)
+}
+
+// EvaluateChanges admits resources, and objects deleted by changes, under
+// every binding whose policy matches them.
+func (e *AdmissionEvaluator) EvaluateChanges(resources []render.Resource, changes []diff.Change, _ string) []Finding {
+	findings := []Finding{}
+	if len(e.bindings) == 0 {
+		return findings
+	}
+	for _, req := range e.requests(resources, changes) {
+		for _, b := range e.bindings {
+			p, ok := e.policies[b.Spec.PolicyName]
+			if !ok || !e.matches(p.Spec.MatchConstraints, req) || (b.Spec.MatchResources != nil && !e.matches(b.Spec.MatchResources, req)) {
+				continue
+			}
+			findings = append(findings, e.admit(p, b, req)...)
+		}
+	}
+	return findings
+}
+
+// Lookups returns the param objects and Namespaces that admitting resources
+// and the deletes in changes may read but neither the render nor the live
+// state holds.
+func (e *AdmissionEvaluator) Lookups(resources []render.Resource, changes []diff.Change) []render.Resource {
+	out := []render.Resource{}
+	if len(e.bindings) == 0 {
+		return out
+	}
+	seen := map[string]struct{}{}
+	want := func(r render.Resource) {
+		if _, ok := seen[r.ID()]; ok || e.lookup(r) != nil {
+			return
+		}
+		seen[r.ID()] = struct{}{}
+		out = append(out, r)
+	}
+	for _, req := range e.requests(resources, changes) {
+		if ns := req.resource.Namespace; ns != "" {
+			want(render.Resource{APIVersion: "v1", Kind: "Namespace", Name: ns})
+		}
+		for _, b := range e.bindings {
+			if p, ok := e.policies[b.Spec.PolicyName]; ok && p.Spec.ParamKind != nil && b.Spec.ParamRef != nil {
+				want(paramObject(p, b, req))
+			}
+		}
+	}
+	return out
+}
+
+// Resolve adds the objects of Lookups the cluster returned. Lookups it did
+// not return are then known not to exist.
+func (e *AdmissionEvaluator) Resolve(found []render.Resource) {
+	for _, r := range found {
+		if _, ok := e.live[r.ID()]; !ok {
+			e.live[r.ID()] = r
+		}
+	}
+	e.resolved = true
+}
+
+// paramObject identifies the param b binds for req.
+func paramObject(p admissionPolicy, b admissionBinding, req admissionRequest) render.Resource {
+	ns := b.Spec.ParamRef.Namespace
+	if ns == "" {
+		ns = req.resource.Namespace
+	}
+	return render.Resource{APIVersion: p.Spec.ParamKind.APIVersion, Kind: p.Spec.ParamKind.Kind, Namespace: ns, Name: b.Spec.ParamRef.Name}
+}
+
+func (e *AdmissionEvaluator) requests(resources []render.Resource, changes []diff.Change) []admissionRequest {
+	actions := map[string]diff.Action{}
+	for _, c := range changes {
+		actions[c.ID] = c.Action
+	}
+	out := []admissionRequest{}
+	for _, r := range resources {
+		req := admissionRequest{id: r.ID(), operation: "CREATE", resource: r, object: r.Body}
+		if old, ok := e.live[r.ID()]; ok {
+			req.operation, req.oldObject = "UPDATE", old.Body
+		}
+		if action, ok := actions[r.ID()]; ok && (action == diff.NoOp || action == diff.Delete) {
+			continue
+		}
+		out = append(out, req)
+	}
+	for _, c := range changes {
+		if c.Action != diff.Delete {
+			continue
+		}
+		old, ok := e.live[c.ID]
+		if !ok {
+			continue
+		}
+		out = append(out, admissionRequest{id: c.ID, operation: "DELETE", resource: old, oldObject: old.Body})
+	}
+	return out
+}
+
+func (e *AdmissionEvaluator) matches(m *matchResources, req admissionRequest) bool {
+	if m == nil {
+		return false
+	}
+	r := req.resource
+	// Bindings without resourceRules match everything their policy matches.
+	if (len(m.ResourceRules) > 0 && !ruleMatches(m.ResourceRules, r, req.operation)) || ruleMatches(m.ExcludeResourceRules, r, req.operation) {
+		return false
+	}
+	if m.ObjectSelector != nil {
+		obj := req.object
+		if obj == nil {
+			obj = req.oldObject
+		}
+		if !selectorMatches(m.ObjectSelector, bodyLabels(obj)) {
+			return false
+		}
+	}
+	if m.NamespaceSelector != nil && r.Namespace != "" {
+		ns := e.lookup(render.Resource{APIVersion: "v1", Kind: "Namespace", Name: r.Namespace})
+		if !selectorMatches(m.NamespaceSelector, bodyLabels(ns)) {
+			return false
+		}
+	}
+	return true
+}
+
+func ruleMatches(rules []resourceRule, r render.Resource, operation string) bool {
+	group, version := "", r.APIVersion
+	if i := strings.Index(r.APIVersion, "/"); i >= 0 {
+		group, version = r.APIVersion[:i], r.APIVersion[i+1:]
+	}
+	scope := "Cluster"
+	if r.Namespace != "" {
+		scope = "Namespaced"
+	}
+	for _, rule := range rules {
+		if contains(rule.APIGroups, group) && contains(rule.APIVersions, version) && contains(rule.Resources, resourceName(r.Kind)) &&
+			contains(rule.Operations, operation) && (len(rule.ResourceNames) == 0 || contains(rule.ResourceNames, r.Name)) &&
+			(rule.Scope == "" || rule.Scope == "*" || rule.Scope == scope) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == "*" || candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceName guesses the API resource of a kind.
+func resourceName(kind string) string {
+	if r, ok := irregularResources[kind]; ok {
+		return r
+	}
+	k := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(k, "s"), strings.HasSuffix(k, "x"), strings.HasSuffix(k, "ch"), strings.HasSuffix(k, "sh"):
+		return k + "es"
+	case strings.HasSuffix(k, "y") && len(k) > 1 && !strings.ContainsRune("aeiou", rune(k[len(k)-2])):
+		return k[:len(k)-1] + "ies"
+	}
+	return k + "s"
+}
+
+func selectorMatches(s *metav1.LabelSelector, set map[string]string) bool {
+	selector, err := metav1.LabelSelectorAsSelector(s)
+	return err == nil && selector.Matches(labels.Set(set))
+}
+
+func bodyLabels(body map[string]any) map[string]string {
+	meta, _ := body["metadata"].(map[string]any)
+	raw, _ := meta["labels"].(map[string]any)
+	out := map[string]string{}
+	for k, v := range raw {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// lookup finds an object in the render, falling back to the live state.
+func (e *AdmissionEvaluator) lookup(r render.Resource) map[string]any {
+	if d, ok := e.declared[r.ID()]; ok {
+		return d.Body
+	}
+	if l, ok := e.live[r.ID()]; ok {
+		return l.Body
+	}
+	return nil
+}
+
+// admit evaluates one policy binding against req.
+func (e *AdmissionEvaluator) admit(p admissionPolicy, b admissionBinding, req admissionRequest) []Finding {
+	ruleID := "vap/" + p.Name
+	severity := SeverityWarn
+	for _, action := range b.Spec.ValidationActions {
+		if action == "Deny" {
+			severity = SeverityError
+		}
+	}
+	failure := func(err error) []Finding {
+		if p.Spec.FailurePolicy == "Ignore" {
+			return nil
+		}
+		return []Finding{{ResourceID: req.id, RuleID: ruleID, Severity: severity, Message: fmt.Sprintf("ValidatingAdmissionPolicy %s could not be evaluated: %v", p.Name, err)}}
+	}
+
+	var params map[string]any
+	if p.Spec.ParamKind != nil && b.Spec.ParamRef != nil {
+		param := paramObject(p, b, req)
+		params = e.lookup(param)
+		if params == nil {
+			if b.Spec.ParamRef.ParameterNotFoundAction != "Deny" {
+				return nil
+			}
+			if !e.resolved {
+				// The param may exist in the cluster, which was not asked.
+				severity = SeverityWarn
+				return failure(fmt.Errorf("%s %s/%s is not in the render and could not be looked up in the cluster", param.Kind, param.Namespace, param.Name))
+			}
+			return failure(fmt.Errorf("%s %s/%s not found in the render or cluster", param.Kind, param.Namespace, param.Name))
+		}
+	}
+	var namespaceObject map[string]any
+	if req.resource.Namespace != "" {
+		namespaceObject = e.lookup(render.Resource{APIVersion: "v1", Kind: "Namespace", Name: req.resource.Namespace})
+	}
+	group, version := "", req.resource.APIVersion
+	if i := strings.Index(version, "/"); i >= 0 {
+		group, version = version[:i], version[i+1:]
+	}
+	activation := map[string]any{
+		"object":          nullable(req.object),
+		"oldObject":       nullable(req.oldObject),
+		"params":          nullable(params),
+		"namespaceObject": nullable(namespaceObject),
+		"request": map[string]any{
+			"operation": req.operation,
+			"name":      req.resource.Name,
+			"namespace": req.resource.Namespace,
+			"kind":      map[string]any{"group": group, "version": version, "kind": req.resource.Kind},
+			"resource":  map[string]any{"group": group, "version": version, "resource": resourceName(req.resource.Kind)},
+			"userInfo":  map[string]any{"username": "thule"},
+			"dryRun":    false,
+		},
+	}
+	variables := map[string]any{}
+	activation["variables"] = variables
+	for _, v := range p.Spec.Variables {
+		val, err := e.evalCEL(v.Expression, activation)
+		if err != nil {
+			return failure(fmt.Errorf("variable %s: %w", v.Name, err))
+		}
+		variables[v.Name] = val
+	}
+	for _, c := range p.Spec.MatchConditions {
+		val, err := e.evalCEL(c.Expression, activation)
+		if err != nil {
+			return failure(fmt.Errorf("match condition %s: %w", c.Name, err))
+		}
+		if matched, _ := val.(bool); !matched {
+			return nil
+		}
+	}
+	findings := []Finding{}
+	for _, v := range p.Spec.Validations {
+		val, err := e.evalCEL(v.Expression, activation)
+		if err != nil {
+			findings = append(findings, failure(err)...)
+			continue
+		}
+		if passed, _ := val.(bool); passed {
+			continue
+		}
+		msg := v.Message
+		if v.MessageExpression != "" {
+			if out, err := e.evalCEL(v.MessageExpression, activation); err == nil {
+				if s, ok := out.(string); ok && strings.TrimSpace(s) != "" {
+					msg = s
+				}
+			}
+		}
+		if msg == "" {
+			msg = "failed expression: " + v.Expression
+		}
+		findings = append(findings, Finding{ResourceID: req.id, RuleID: ruleID, Severity: severity, Message: fmt.Sprintf("ValidatingAdmissionPolicy %s (binding %s) rejects %s: %s", p.Name, b.Name, strings.ToLower(req.operation), msg)})
+	}
+	return findings
+}
+
+// celEnv declares the admission variables on the Kubernetes CEL base
+// environment, so expressions can use the same libraries as the API server.
+var celEnv = func() *cel.Env {
+	base := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()).StoredExpressionsEnv()
+	env, err := base.Extend(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("params", cel.DynType),
+		cel.Variable("namespaceObject", cel.DynType),
+		cel.Variable("request", cel.DynType),
+		cel.Variable("variables", cel.DynType),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("admission CEL environment: %v", err))
+	}
+	return env
+}()
+
+// evalCEL compiles (once) and runs expr, returning its value as a native Go
+// value.
+func (e *AdmissionEvaluator) evalCEL(expr string, activation map[string]any) (any, error) {
+	prg, ok := e.programs[expr]
+	if !ok {
+		ast, iss := celEnv.Compile(expr)
+		if iss.Err() != nil {
+			return nil, iss.Err()
+		}
+		var err error
+		if prg, err = celEnv.Program(ast); err != nil {
+			return nil, err
+		}
+		e.programs[expr] = prg
+	}
+	out, _, err := prg.Eval(activation)
+	if err != nil {
+		return nil, err
+	}
+	if types.IsError(out) {
+		return nil, fmt.Errorf("%v", out)
+	}
+	return out.Value(), nil
+}
+
+// nullable keeps missing objects null in CEL; a nil map would read as {}.
+func nullable(m map[string]any) any {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+// decodeBody converts a decoded manifest into a typed struct. Bodies go
+// through YAML first since render output can hold non-JSON values.
+func decodeBody(body map[string]any, out any) error {
+	raw, err := yaml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := yaml.Unmarshal(raw, &generic); err != nil {
+		return err
+	}
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
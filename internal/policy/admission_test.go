@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+func admissionResource(kind, name string, spec map[string]any) render.Resource {
+	return render.Resource{APIVersion: "admissionregistration.k8s.io/v1", Kind: kind, Name: name, Body: map[string]any{
+		"apiVersion": "admissionregistration.k8s.io/v1", "kind": kind, "metadata": map[string]any{"name": name}, "spec": spec,
+	}}
+}
+
+func deployment(name string, replicas int, labels map[string]any) render.Resource {
+	return render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "payments", Name: name, Body: map[string]any{
+		"apiVersion": "apps/v1", "kind": "Deployment",
+		"metadata": map[string]any{"name": name, "namespace": "payments", "labels": labels},
+		"spec":     map[string]any{"replicas": replicas},
+	}}
+}
+
+func TestAdmissionEvaluator(t *testing.T) {
+	replicaLimit := admissionResource("ValidatingAdmissionPolicy", "replica-limit", map[string]any{
+		"failurePolicy": "Fail",
+		"paramKind":     map[string]any{"apiVersion": "v1", "kind": "ConfigMap"},
+		"matchConstraints": map[string]any{"resourceRules": []any{map[string]any{
+			"apiGroups": []any{"apps"}, "apiVersions": []any{"v1"}, "resources": []any{"deployments"}, "operations": []any{"CREATE", "UPDATE"},
+		}}},
+		"matchConditions": []any{map[string]any{"name": "not-exempt", "expression": "!has(object.metadata.labels.exempt)"}},
+		"variables":       []any{map[string]any{"name": "max", "expression": "int(params.data.maxReplicas)"}},
+		"validations": []any{
+			map[string]any{"expression": "object.spec.replicas <= variables.max", "messageExpression": "'replicas must be at most ' + string(variables.max)"},
+			map[string]any{"expression": "oldObject == null || object.spec.replicas >= oldObject.spec.replicas / 2", "message": "replicas cannot be halved in one change"},
+		},
+	})
+	binding := admissionResource("ValidatingAdmissionPolicyBinding", "replica-limit-prod", map[string]any{
+		"policyName":        "replica-limit",
+		"validationActions": []any{"Deny"},
+		"paramRef":          map[string]any{"name": "limits", "namespace": "platform"},
+		"matchResources":    map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"env": "prod"}}},
+	})
+	limits := render.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "platform", Name: "limits", Body: map[string]any{"data": map[string]any{"maxReplicas": "5"}}}
+	namespace := render.Resource{APIVersion: "v1", Kind: "Namespace", Name: "payments", Body: map[string]any{"metadata": map[string]any{"name": "payments", "labels": map[string]any{"env": "prod"}}}}
+
+	tooMany := deployment("api", 8, nil)
+	halved := deployment("web", 2, nil)
+	exempt := deployment("batch", 9, map[string]any{"exempt": "true"})
+	liveWeb := deployment("web", 6, nil)
+
+	e := NewAdmissionEvaluator([]render.Resource{binding, limits, tooMany, halved, exempt}, []render.Resource{replicaLimit, namespace, liveWeb})
+	changes := []diff.Change{{ID: tooMany.ID(), Action: diff.Create}, {ID: halved.ID(), Action: diff.Patch}, {ID: exempt.ID(), Action: diff.Create}}
+	findings := e.EvaluateChanges([]render.Resource{tooMany, halved, exempt}, changes, "")
+	if len(findings) != 2 {
+		t.Fatalf("expected two findings, got %+v", findings)
+	}
+	if f := findings[0]; f.ResourceID != tooMany.ID() || f.RuleID != "vap/replica-limit" || f.Severity != SeverityError || !strings.HasSuffix(f.Message, "rejects create: replicas must be at most 5") {
+		t.Fatalf("unexpected replica finding %+v", f)
+	}
+	if f := findings[1]; f.ResourceID != halved.ID() || !strings.HasSuffix(f.Message, "rejects update: replicas cannot be halved in one change") {
+		t.Fatalf("unexpected oldObject finding %+v", f)
+	}
+
+	// Outside the bound namespaces nothing is admitted.
+	staging := render.Resource{APIVersion: "v1", Kind: "Namespace", Name: "payments", Body: map[string]any{"metadata": map[string]any{"labels": map[string]any{"env": "staging"}}}}
+	e = NewAdmissionEvaluator([]render.Resource{binding, limits, staging}, []render.Resource{replicaLimit, namespace})
+	if got := e.Evaluate([]render.Resource{tooMany}, ""); len(got) != 0 {
+		t.Fatalf("expected the rendered Namespace labels to win, got %+v", got)
+	}
+}
+
+func TestAdmissionEvaluatorLooksUpClusterParams(t *testing.T) {
+	policy := admissionResource("ValidatingAdmissionPolicy", "replica-limit", map[string]any{
+		"paramKind": map[string]any{"apiVersion": "v1", "kind": "ConfigMap"},
+		"matchConstraints": map[string]any{"resourceRules": []any{map[string]any{
+			"apiGroups": []any{"apps"}, "apiVersions": []any{"v1"}, "resources": []any{"deployments"}, "operations": []any{"CREATE"},
+		}}},
+		"validations": []any{map[string]any{"expression": "object.spec.replicas <= int(params.data.maxReplicas)"}},
+	})
+	binding := admissionResource("ValidatingAdmissionPolicyBinding", "replica-limit", map[string]any{
+		"policyName":        "replica-limit",
+		"validationActions": []any{"Deny"},
+		"paramRef":          map[string]any{"name": "limits", "parameterNotFoundAction": "Deny"},
+	})
+	api := deployment("api", 8, nil)
+
+	e := NewAdmissionEvaluator([]render.Resource{policy, binding, api}, nil)
+	lookups := e.Lookups([]render.Resource{api}, nil)
+	got := []string{}
+	for _, r := range lookups {
+		got = append(got, r.ID())
+	}
+	if strings.Join(got, " ") != "v1|Namespace|_cluster|payments v1|ConfigMap|payments|limits" {
+		t.Fatalf("unexpected lookups %v", got)
+	}
+	// Without the cluster a missing param may still exist there.
+	findings := e.Evaluate([]render.Resource{api}, "")
+	if len(findings) != 1 || findings[0].Severity != SeverityWarn || !strings.Contains(findings[0].Message, "could not be looked up in the cluster") {
+		t.Fatalf("expected a warning for the unresolved param, got %+v", findings)
+	}
+	e.Resolve(nil)
+	findings = e.Evaluate([]render.Resource{api}, "")
+	if len(findings) != 1 || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, "not found in the render or cluster") {
+		t.Fatalf("expected an error for a param the cluster lacks, got %+v", findings)
+	}
+
+	e = NewAdmissionEvaluator([]render.Resource{policy, binding, api}, nil)
+	e.Resolve([]render.Resource{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "payments", Name: "limits", Body: map[string]any{"data": map[string]any{"maxReplicas": "5"}}}})
+	findings = e.Evaluate([]render.Resource{api}, "")
+	if len(findings) != 1 || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, "rejects create") {
+		t.Fatalf("expected the fetched param to be used, got %+v", findings)
+	}
+}
+
+func TestAdmissionEvaluatorFailurePolicies(t *testing.T) {
+	policy := func(name, failurePolicy string) render.Resource {
+		return admissionResource("ValidatingAdmissionPolicy", name, map[string]any{
+			"failurePolicy": failurePolicy,
+			"matchConstraints": map[string]any{"resourceRules": []any{map[string]any{
+				"apiGroups": []any{"*"}, "apiVersions": []any{"*"}, "resources": []any{"*"}, "operations": []any{"DELETE"},
+			}}},
+			"validations": []any{map[string]any{"expression": "oldObject.spec.missing.field == 1"}},
+		})
+	}
+	bind := func(name string) render.Resource {
+		return admissionResource("ValidatingAdmissionPolicyBinding", name, map[string]any{"policyName": name, "validationActions": []any{"Warn"}})
+	}
+	live := deployment("web", 1, nil)
+	e := NewAdmissionEvaluator(nil, []render.Resource{policy("strict", "Fail"), bind("strict"), policy("lenient", "Ignore"), bind("lenient"), live})
+	findings := e.EvaluateChanges(nil, []diff.Change{{ID: live.ID(), Action: diff.Delete}}, "")
+	if len(findings) != 1 || findings[0].RuleID != "vap/strict" || findings[0].Severity != SeverityWarn || !strings.Contains(findings[0].Message, "could not be evaluated") {
+		t.Fatalf("expected one evaluation failure for the Fail policy, got %+v", findings)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	for kind, want := range map[string]string{"Deployment": "deployments", "Ingress": "ingresses", "NetworkPolicy": "networkpolicies", "Gateway": "gateways", "Endpoints": "endpoints"} {
+		if got := resourceName(kind); got != want {
+			t.Fatalf("resourceName(%s) = %s, want %s", kind, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/example/thule/internal/render"
+)
+
+// apiDeprecation records when Kubernetes deprecated and removed serving an
+// API version for some kinds. Versions are "major.minor".
+type apiDeprecation struct {
+	APIVersion   string
+	Kinds        []string
+	DeprecatedIn string
+	RemovedIn    string
+	// Replacement is the API version to migrate to; empty when the API was
+	// dropped without one.
+	Replacement string
+}
+
+// apiDeprecations follows the upstream deprecated API migration guide.
+var apiDeprecations = []apiDeprecation{
+	{"extensions/v1beta1", []string{"Deployment", "DaemonSet", "ReplicaSet"}, "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", []string{"NetworkPolicy"}, "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", []string{"PodSecurityPolicy"}, "1.11", "1.16", "policy/v1beta1"},
+	{"extensions/v1beta1", []string{"Ingress"}, "1.14", "1.22", "networking.k8s.io/v1"},
+	{"apps/v1beta1", []string{"Deployment", "StatefulSet", "ReplicaSet"}, "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet"}, "1.9", "1.16", "apps/v1"},
+	{"networking.k8s.io/v1beta1", []string{"Ingress", "IngressClass"}, "1.19", "1.22", "networking.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", []string{"CustomResourceDefinition"}, "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", []string{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"}, "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", []string{"APIService"}, "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", []string{"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"}, "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", []string{"PriorityClass"}, "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", []string{"CSIDriver", "CSINode", "StorageClass", "VolumeAttachment"}, "1.19", "1.22", "storage.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", []string{"CertificateSigningRequest"}, "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", []string{"Lease"}, "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"batch/v1beta1", []string{"CronJob"}, "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io/v1beta1", []string{"EndpointSlice"}, "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", []string{"Event"}, "1.19", "1.25", "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", []string{"HorizontalPodAutoscaler"}, "1.22", "1.25", "autoscaling/v2"},
+	{"autoscaling/v2beta2", []string{"HorizontalPodAutoscaler"}, "1.23", "1.26", "autoscaling/v2"},
+	{"policy/v1beta1", []string{"PodDisruptionBudget"}, "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", []string{"PodSecurityPolicy"}, "1.21", "1.25", ""},
+	{"node.k8s.io/v1beta1", []string{"RuntimeClass"}, "1.20", "1.25", "node.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", []string{"FlowSchema", "PriorityLevelConfiguration"}, "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", []string{"FlowSchema", "PriorityLevelConfiguration"}, "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", []string{"FlowSchema", "PriorityLevelConfiguration"}, "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", []string{"CSIStorageCapacity"}, "1.24", "1.27", "storage.k8s.io/v1"},
+}
+
+// CheckDeprecatedAPIs reports resources whose apiVersion the cluster running
+// kubeVersion (e.g. "v1.29.4") no longer serves (removed-api, ERROR) or
+// serves as deprecated (deprecated-api, WARN). Nothing is reported when the
+// version is unknown.
+func CheckDeprecatedAPIs(resources []render.Resource, kubeVersion string) []Finding {
+	findings := []Finding{}
+	cluster, ok := ParseKubeVersion(kubeVersion)
+	if !ok {
+		return findings
+	}
+	for _, r := range resources {
+		d, ok := findDeprecation(r.APIVersion, r.Kind)
+		if !ok {
+			continue
+		}
+		removed, _ := ParseKubeVersion(d.RemovedIn)
+		deprecated, _ := ParseKubeVersion(d.DeprecatedIn)
+		migrate := "no replacement is served"
+		if d.Replacement != "" {
+			migrate = "migrate to " + d.Replacement
+		}
+		switch {
+		case !minorLess(cluster, removed):
+			findings = append(findings, Finding{ResourceID: r.ID(), RuleID: "removed-api", Severity: SeverityError, Message: fmt.Sprintf("%s %s was removed in Kubernetes v%s and is not served by the cluster (v%d.%d); %s", r.APIVersion, r.Kind, d.RemovedIn, cluster[0], cluster[1], migrate)})
+		case !minorLess(cluster, deprecated):
+			findings = append(findings, Finding{ResourceID: r.ID(), RuleID: "deprecated-api", Severity: SeverityWarn, Message: fmt.Sprintf("%s %s is deprecated since Kubernetes v%s and removed in v%s; %s", r.APIVersion, r.Kind, d.DeprecatedIn, d.RemovedIn, migrate)})
+		}
+	}
+	return findings
+}
+
+func findDeprecation(apiVersion, kind string) (apiDeprecation, bool) {
+	for _, d := range apiDeprecations {
+		if d.APIVersion != apiVersion {
+			continue
+		}
+		for _, k := range d.Kinds {
+			if k == kind {
+				return d, true
+			}
+		}
+	}
+	return apiDeprecation{}, false
+}
+
+// ParseKubeVersion reads major, minor and patch from "v1.29.4", "1.29" or
+// provider builds such as "v1.27.3-gke.100" and "1.27+"; a missing or
+// non-numeric patch is zero. ok is false unless major and minor are numbers.
+func ParseKubeVersion(v string) (version [3]int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(v), "v"), ".", 3)
+	if len(parts) < 2 {
+		return [3]int{}, false
+	}
+	for i, part := range parts {
+		if end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			part = part[:end]
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil && i < 2 {
+			return [3]int{}, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+func minorLess(a, b [3]int) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestCheckDeprecatedAPIs(t *testing.T) {
+	cronJob := render.Resource{APIVersion: "batch/v1beta1", Kind: "CronJob", Namespace: "n", Name: "backup"}
+	hpa := render.Resource{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Namespace: "n", Name: "web"}
+	psp := render.Resource{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", Name: "restricted"}
+	current := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web"}
+	resources := []render.Resource{cronJob, hpa, psp, current}
+
+	findings := CheckDeprecatedAPIs(resources, "v1.25.3-gke.100")
+	if len(findings) != 3 {
+		t.Fatalf("expected three findings, got %+v", findings)
+	}
+	if f := findings[0]; f.RuleID != "removed-api" || f.Severity != SeverityError || f.ResourceID != cronJob.ID() || !strings.Contains(f.Message, "removed in Kubernetes v1.25") || !strings.HasSuffix(f.Message, "migrate to batch/v1") {
+		t.Fatalf("unexpected CronJob finding %+v", f)
+	}
+	if f := findings[1]; f.RuleID != "deprecated-api" || f.Severity != SeverityWarn || !strings.Contains(f.Message, "removed in v1.26; migrate to autoscaling/v2") {
+		t.Fatalf("unexpected HPA finding %+v", f)
+	}
+	if f := findings[2]; f.RuleID != "removed-api" || !strings.HasSuffix(f.Message, "no replacement is served") {
+		t.Fatalf("unexpected PodSecurityPolicy finding %+v", f)
+	}
+
+	if got := CheckDeprecatedAPIs(resources, "1.22"); len(got) != 2 || got[0].ResourceID != cronJob.ID() || got[1].ResourceID != psp.ID() || got[1].RuleID != "deprecated-api" {
+		t.Fatalf("expected CronJob and PodSecurityPolicy deprecations on 1.22, got %+v", got)
+	}
+	if got := CheckDeprecatedAPIs(resources, ""); len(got) != 0 {
+		t.Fatalf("expected no findings without a cluster version, got %+v", got)
+	}
+}
+
+func TestParseKubeVersion(t *testing.T) {
+	cases := map[string][3]int{
+		"v1.29.4":         {1, 29, 4},
+		"1.29":            {1, 29, 0},
+		"v1.27.3-gke.100": {1, 27, 3},
+		"1.27+":           {1, 27, 0},
+	}
+	for in, want := range cases {
+		if got, ok := ParseKubeVersion(in); !ok || got != want {
+			t.Fatalf("ParseKubeVersion(%q) = %v, %v", in, got, ok)
+		}
+	}
+	for _, in := range []string{"", "v1", "latest", "v1.x"} {
+		if _, ok := ParseKubeVersion(in); ok {
+			t.Fatalf("expected %q not to parse", in)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+// protectedKinds take data or dependent objects with them when deleted.
+var protectedKinds = map[string]string{
+	"Namespace":                "deletes every object in the namespace",
+	"PersistentVolumeClaim":    "may delete the volume and its data, depending on the reclaim policy",
+	"PersistentVolume":         "may delete the backing storage, depending on the reclaim policy",
+	"CustomResourceDefinition": "deletes every custom resource of this type",
+}
+
+// scalableKinds are workloads scale-to-zero is reported for.
+var scalableKinds = map[string]struct{}{
+	"Deployment":  {},
+	"StatefulSet": {},
+	"ReplicaSet":  {},
+}
+
+// EvaluateChanges adds the destructive-operation rules, which look at the
+// planned changes, to the resource rules of Evaluate:
+//
+//   - delete-protected-kind (ERROR): a Namespace, PVC, PV or CRD is deleted,
+//     or renamed (which deletes the old object).
+//   - scale-to-zero (WARN): a workload's replicas drop from a positive count
+//     to 0.
+//   - crd-version-removed (ERROR): a CRD stops serving a version it serves
+//     live; objects and clients using it break.
+func (e *BuiltinEvaluator) EvaluateChanges(resources []render.Resource, changes []diff.Change, profile string) []Finding {
+	findings := e.Evaluate(resources, profile)
+	for _, c := range changes {
+		kind := resourceKind(c)
+		switch c.Action {
+		case diff.Delete, diff.Rename, diff.Move:
+			if reason, ok := protectedKinds[kind]; ok {
+				msg := fmt.Sprintf("%s is deleted; this %s", kind, reason)
+				if c.PreviousID != "" {
+					msg = fmt.Sprintf("%s is replaced by a new object, deleting %s; this %s", kind, c.PreviousID, reason)
+				}
+				findings = append(findings, Finding{ResourceID: c.ID, RuleID: "delete-protected-kind", Severity: SeverityError, Message: msg})
+			}
+		}
+		if c.Action != diff.Patch && c.Action != diff.Rename && c.Action != diff.Move {
+			continue
+		}
+		if _, ok := scalableKinds[kind]; ok {
+			before, hadBefore := replicas(c.Current)
+			after, hasAfter := replicas(c.Desired)
+			if hadBefore && hasAfter && before > 0 && after == 0 {
+				findings = append(findings, Finding{ResourceID: c.ID, RuleID: "scale-to-zero", Severity: SeverityWarn, Message: fmt.Sprintf("replicas drop from %d to 0; the workload stops serving", before)})
+			}
+		}
+		if kind == "CustomResourceDefinition" {
+			for _, v := range removedCRDVersions(c.Current, c.Desired) {
+				findings = append(findings, Finding{ResourceID: c.ID, RuleID: "crd-version-removed", Severity: SeverityError, Message: fmt.Sprintf("version %s is no longer served; stored objects and clients using it break", v)})
+			}
+		}
+	}
+	return findings
+}
+
+func resourceKind(c diff.Change) string {
+	for _, body := range []map[string]any{c.Desired, c.Current} {
+		if kind, ok := body["kind"].(string); ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+func replicas(body map[string]any) (int, bool) {
+	spec, _ := body["spec"].(map[string]any)
+	switch n := spec["replicas"].(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// removedCRDVersions lists versions served by current but not by desired.
+func removedCRDVersions(current, desired map[string]any) []string {
+	served := func(body map[string]any) map[string]struct{} {
+		out := map[string]struct{}{}
+		spec, _ := body["spec"].(map[string]any)
+		versions, _ := spec["versions"].([]any)
+		for _, item := range versions {
+			v, _ := item.(map[string]any)
+			name, _ := v["name"].(string)
+			if s, ok := v["served"].(bool); name != "" && (!ok || s) {
+				out[name] = struct{}{}
+			}
+		}
+		return out
+	}
+	after := served(desired)
+	removed := []string{}
+	for v := range served(current) {
+		if _, ok := after[v]; !ok {
+			removed = append(removed, v)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
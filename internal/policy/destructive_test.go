@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/diff"
+)
+
+func TestBuiltinEvaluatorDestructiveChanges(t *testing.T) {
+	crd := func(versions ...any) map[string]any {
+		return map[string]any{"kind": "CustomResourceDefinition", "spec": map[string]any{"versions": versions}}
+	}
+	changes := []diff.Change{
+		{ID: "v1|PersistentVolumeClaim|n|data", Action: diff.Delete, Current: map[string]any{"kind": "PersistentVolumeClaim"}},
+		{ID: "v1|ConfigMap|n|settings", Action: diff.Delete, Current: map[string]any{"kind": "ConfigMap"}},
+		{ID: "v1|Namespace|_cluster|new", PreviousID: "v1|Namespace|_cluster|old", Action: diff.Rename, Current: map[string]any{"kind": "Namespace"}, Desired: map[string]any{"kind": "Namespace"}},
+		{ID: "apps/v1|Deployment|n|web", Action: diff.Patch,
+			Current: map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": 3}},
+			Desired: map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": 0}}},
+		{ID: "apps/v1|Deployment|n|api", Action: diff.Patch,
+			Current: map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": 3}},
+			Desired: map[string]any{"kind": "Deployment", "spec": map[string]any{"replicas": 2}}},
+		{ID: "apiextensions.k8s.io/v1|CustomResourceDefinition|_cluster|widgets.example.com", Action: diff.Patch,
+			Current: crd(map[string]any{"name": "v1alpha1", "served": true}, map[string]any{"name": "v1beta1", "served": true}, map[string]any{"name": "v1", "served": true}),
+			Desired: crd(map[string]any{"name": "v1beta1", "served": false}, map[string]any{"name": "v1", "served": true})},
+	}
+	findings := NewBuiltinEvaluator().EvaluateChanges(nil, changes, "")
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, string(f.Severity)+" "+f.RuleID+" "+f.ResourceID)
+	}
+	want := []string{
+		"ERROR delete-protected-kind v1|PersistentVolumeClaim|n|data",
+		"ERROR delete-protected-kind v1|Namespace|_cluster|new",
+		"WARN scale-to-zero apps/v1|Deployment|n|web",
+		"ERROR crd-version-removed apiextensions.k8s.io/v1|CustomResourceDefinition|_cluster|widgets.example.com",
+		"ERROR crd-version-removed apiextensions.k8s.io/v1|CustomResourceDefinition|_cluster|widgets.example.com",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+	if !strings.Contains(findings[1].Message, "deleting v1|Namespace|_cluster|old") || !strings.Contains(findings[3].Message, "version v1alpha1") || !strings.Contains(findings[4].Message, "version v1beta1") {
+		t.Fatalf("unexpected messages %+v", findings)
+	}
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"fmt"
 
+	"github.com/example/thule/internal/diff"
 	"github.com/example/thule/internal/render"
 )
 
@@ -18,24 +19,63 @@ type Finding struct {
 	RuleID     string
 	Severity   Severity
 	Message    string
+	// Waiver is set when the finding is waived; see ApplyWaivers.
+	Waiver *Waiver
 }
 
 type Evaluator interface {
 	Evaluate(resources []render.Resource, profile string) []Finding
 }
 
+// ChangeEvaluator is implemented by evaluators whose rules also see the diff
+// change planned for each resource.
+type ChangeEvaluator interface {
+	EvaluateChanges(resources []render.Resource, changes []diff.Change, profile string) []Finding
+}
+
+// Evaluators runs several evaluators and concatenates their findings.
+type Evaluators []Evaluator
+
+func (e Evaluators) Evaluate(resources []render.Resource, profile string) []Finding {
+	return e.EvaluateChanges(resources, nil, profile)
+}
+
+func (e Evaluators) EvaluateChanges(resources []render.Resource, changes []diff.Change, profile string) []Finding {
+	findings := []Finding{}
+	for _, evaluator := range e {
+		if changeAware, ok := evaluator.(ChangeEvaluator); ok {
+			findings = append(findings, changeAware.EvaluateChanges(resources, changes, profile)...)
+		} else {
+			findings = append(findings, evaluator.Evaluate(resources, profile)...)
+		}
+	}
+	return findings
+}
+
 type BuiltinEvaluator struct{}
 
 func NewBuiltinEvaluator() *BuiltinEvaluator {
 	return &BuiltinEvaluator{}
 }
 
+// Evaluate runs the security pack (see securityFindings) and the review rules
+// for Secrets and, under the strict profile, ClusterRoleBindings. Projects
+// without a profile get the baseline rules as warnings only, so the pack
+// does not fail thule/policy until a profile is chosen.
 func (e *BuiltinEvaluator) Evaluate(resources []render.Resource, profile string) []Finding {
-	if profile == "" {
+	defaulted := profile == ""
+	if defaulted {
 		profile = "baseline"
 	}
 	findings := []Finding{}
 	for _, r := range resources {
+		security := securityFindings(r, restrictedProfile(profile))
+		for i := range security {
+			if defaulted {
+				security[i].Severity = SeverityWarn
+			}
+		}
+		findings = append(findings, security...)
 		if r.Kind == "Secret" {
 			findings = append(findings, Finding{ResourceID: r.ID(), RuleID: "review-secret-change", Severity: SeverityWarn, Message: "Secret change detected; validate secret rotation and source of truth"})
 		}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/example/thule/internal/render"
+)
+
+// implicitObjects exist in every namespace without being declared.
+var implicitObjects = map[string]struct{}{
+	"ServiceAccount|default":     {},
+	"ConfigMap|kube-root-ca.crt": {},
+}
+
+// CheckReferences reports references from workloads in desired to
+// ConfigMaps, Secrets, PersistentVolumeClaims and ServiceAccounts that exist
+// neither in rendered nor according to live. References from any rendered
+// workload to objects in removed (dropped by the MR) are reported even when
+// the object still exists. live may be nil when the cluster is not consulted;
+// dangling references are then not reported. Optional references are skipped.
+func CheckReferences(desired, rendered, removed []render.Resource, live func(render.Reference) bool) []Finding {
+	declared := map[string]struct{}{}
+	for _, r := range rendered {
+		declared[r.ID()] = struct{}{}
+	}
+	removedIDs := map[string]struct{}{}
+	for _, r := range removed {
+		removedIDs[r.ID()] = struct{}{}
+	}
+
+	findings := []Finding{}
+	seen := map[string]struct{}{}
+	report := func(f Finding) {
+		key := f.ResourceID + "|" + f.RuleID + "|" + f.Message
+		if _, dup := seen[key]; !dup {
+			seen[key] = struct{}{}
+			findings = append(findings, f)
+		}
+	}
+	for _, r := range rendered {
+		for _, ref := range r.References() {
+			if _, ok := removedIDs[ref.ID()]; ok && !ref.Optional {
+				report(Finding{ResourceID: r.ID(), RuleID: "removed-referenced-object", Severity: SeverityError, Message: fmt.Sprintf("%s `%s` is removed by this change but still referenced via %s", ref.Kind, ref.Name, ref.Via)})
+			}
+		}
+	}
+	if live == nil {
+		return findings
+	}
+	for _, r := range desired {
+		for _, ref := range r.References() {
+			if ref.Optional {
+				continue
+			}
+			if _, ok := implicitObjects[ref.Kind+"|"+ref.Name]; ok {
+				continue
+			}
+			if _, ok := declared[ref.ID()]; ok {
+				continue
+			}
+			if _, ok := removedIDs[ref.ID()]; ok || live(ref) {
+				continue
+			}
+			report(Finding{ResourceID: r.ID(), RuleID: "dangling-reference", Severity: SeverityWarn, Message: fmt.Sprintf("%s `%s` referenced via %s exists neither in the repository nor in the cluster", ref.Kind, ref.Name, ref.Via)})
+		}
+	}
+	return findings
+}
+
+// CheckUnresolvedSources reports, once per Flux HelmRelease or Kustomization,
+// the valuesFrom/substituteFrom sources that are not defined in the
+// repository, so the resources expanded from it were rendered without their
+// values or variables.
+func CheckUnresolvedSources(resources []render.Resource) []Finding {
+	findings := []Finding{}
+	seen := map[string]struct{}{}
+	for _, r := range resources {
+		for _, source := range r.Unresolved {
+			key := r.Parent + "|" + source
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			findings = append(findings, Finding{ResourceID: r.Parent, RuleID: "unresolved-flux-source", Severity: SeverityWarn, Message: fmt.Sprintf("%s is not defined in the repository; the expanded resources are planned without it", source)})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestCheckReferences(t *testing.T) {
+	web := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"serviceAccountName": "web",
+			"imagePullSecrets":   []any{map[string]any{"name": "registry"}},
+			"containers": []any{map[string]any{
+				"name":    "web",
+				"envFrom": []any{map[string]any{"configMapRef": map[string]any{"name": "settings"}}, map[string]any{"secretRef": map[string]any{"name": "extra", "optional": true}}},
+			}},
+			"volumes": []any{
+				map[string]any{"name": "data", "persistentVolumeClaim": map[string]any{"claimName": "data"}},
+				map[string]any{"name": "ca", "configMap": map[string]any{"name": "kube-root-ca.crt"}},
+			},
+		}}},
+	}}
+	settings := render.Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "n", Name: "settings"}
+	pvc := render.Resource{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "n", Name: "data"}
+	live := func(ref render.Reference) bool { return ref.Kind == "ServiceAccount" || ref.Name == "data" }
+
+	findings := CheckReferences([]render.Resource{web}, []render.Resource{web, settings}, []render.Resource{pvc}, live)
+	if len(findings) != 2 {
+		t.Fatalf("expected two findings, got %+v", findings)
+	}
+	if findings[0].RuleID != "removed-referenced-object" || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, "PersistentVolumeClaim `data`") {
+		t.Fatalf("unexpected removal finding %+v", findings[0])
+	}
+	if findings[1].RuleID != "dangling-reference" || !strings.Contains(findings[1].Message, "Secret `registry` referenced via imagePullSecrets") {
+		t.Fatalf("unexpected dangling finding %+v", findings[1])
+	}
+
+	if got := CheckReferences([]render.Resource{web}, []render.Resource{web, settings}, nil, nil); len(got) != 0 {
+		t.Fatalf("expected no dangling findings without a cluster, got %+v", got)
+	}
+}
+
+func TestCheckUnresolvedSources(t *testing.T) {
+	unresolved := []string{"valuesFrom Secret/web-values"}
+	resources := []render.Resource{
+		{Kind: "Deployment", Name: "web", Parent: "helm.toolkit.fluxcd.io/v2|HelmRelease|apps|web", Unresolved: unresolved},
+		{Kind: "Service", Name: "web", Parent: "helm.toolkit.fluxcd.io/v2|HelmRelease|apps|web", Unresolved: unresolved},
+		{Kind: "ConfigMap", Name: "plain"},
+	}
+	findings := CheckUnresolvedSources(resources)
+	if len(findings) != 1 || findings[0].ResourceID != "helm.toolkit.fluxcd.io/v2|HelmRelease|apps|web" || findings[0].Severity != SeverityWarn || !strings.HasPrefix(findings[0].Message, "valuesFrom Secret/web-values is not defined") {
+		t.Fatalf("unexpected findings %+v", findings)
+	}
+}
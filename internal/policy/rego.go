@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+// regoRulePrefixes are the conftest rule names Findings are read from and the
+// severity each maps to. Rules may add a suffix, e.g. "deny_privileged".
+var regoRulePrefixes = []struct {
+	Name     string
+	Severity Severity
+}{
+	{"deny", SeverityError},
+	{"violation", SeverityError},
+	{"warn", SeverityWarn},
+}
+
+// RegoEvaluator evaluates conftest-style Rego policies with embedded OPA.
+// Policies are read from Dir on every evaluation: *.rego files directly in
+// Dir apply to every project, files in Dir/<profile> only to projects using
+// that policy.profile. Profiles other than the builtin ones must have their
+// directory. Rego v1 and v0 syntax are both accepted.
+//
+// Each resource is evaluated with input
+//
+//	{"resource": <manifest>, "change": {"id", "action", "changedPaths", "current", "desired"}}
+//
+// where change is null when the resource has no planned change and resource
+// is the live object for deletes. Rules named
+// deny, violation or warn (optionally with a "_<suffix>") in any package may
+// produce strings or objects with a "msg" (and optional "rule") field.
+type RegoEvaluator struct {
+	Dir string
+}
+
+func NewRegoEvaluator(dir string) *RegoEvaluator {
+	return &RegoEvaluator{Dir: dir}
+}
+
+func (e *RegoEvaluator) Evaluate(resources []render.Resource, profile string) []Finding {
+	return e.EvaluateChanges(resources, nil, profile)
+}
+
+// EvaluateChanges evaluates resources with the change planned for each, by
+// resource ID, and objects deleted by changes. Policies that fail to load are
+// reported as a rego-policy-error finding.
+func (e *RegoEvaluator) EvaluateChanges(resources []render.Resource, changes []diff.Change, profile string) []Finding {
+	if profile == "" {
+		profile = "baseline"
+	}
+	queries, err := e.prepare(context.Background(), profile)
+	if err != nil {
+		return []Finding{{RuleID: "rego-policy-error", Severity: SeverityError, Message: err.Error()}}
+	}
+	byID := map[string]diff.Change{}
+	for _, c := range changes {
+		byID[c.ID] = c
+	}
+	type subject struct {
+		id   string
+		body map[string]any
+	}
+	subjects := make([]subject, 0, len(resources))
+	for _, r := range resources {
+		subjects = append(subjects, subject{r.ID(), r.Body})
+	}
+	// Deleted objects are evaluated with their live body.
+	for _, c := range changes {
+		if c.Action == diff.Delete {
+			subjects = append(subjects, subject{c.ID, c.Current})
+		}
+	}
+	findings := []Finding{}
+	for _, sub := range subjects {
+		var change any
+		if c, ok := byID[sub.id]; ok {
+			change = regoChange(c)
+		}
+		input := map[string]any{"resource": sub.body, "change": change}
+		for _, q := range queries {
+			rs, err := q.query.Eval(context.Background(), rego.EvalInput(input))
+			if err != nil {
+				findings = append(findings, Finding{ResourceID: sub.id, RuleID: "rego-policy-error", Severity: SeverityError, Message: fmt.Sprintf("%s: %v", q.ruleID, err)})
+				continue
+			}
+			for _, result := range rs {
+				for _, expr := range result.Expressions {
+					findings = append(findings, regoFindings(sub.id, q, expr.Value)...)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+type regoQuery struct {
+	ruleID   string
+	severity Severity
+	query    rego.PreparedEvalQuery
+}
+
+// prepare compiles the policies for profile and prepares one query per
+// conftest rule.
+func (e *RegoEvaluator) prepare(ctx context.Context, profile string) ([]regoQuery, error) {
+	profileDir := filepath.Join(e.Dir, profile)
+	if filepath.Base(profile) != profile || profile == "." || profile == ".." {
+		return nil, fmt.Errorf("policy profile %q is not a policy subdirectory name", profile)
+	}
+	if _, err := os.Stat(profileDir); os.IsNotExist(err) && !builtinProfile(profile) {
+		return nil, fmt.Errorf("policy profile %q is neither builtin nor a directory in %s", profile, e.Dir)
+	}
+	modules := map[string]*ast.Module{}
+	for _, dir := range []string{e.Dir, profileDir} {
+		if err := loadRegoModules(dir, modules); err != nil {
+			return nil, err
+		}
+	}
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		return nil, fmt.Errorf("compile rego policies: %v", compiler.Errors)
+	}
+	rules := map[string]Severity{}
+	for _, m := range modules {
+		for _, rule := range m.Rules {
+			name := rule.Head.Ref()[0].Value.String()
+			for _, prefix := range regoRulePrefixes {
+				if name == prefix.Name || strings.HasPrefix(name, prefix.Name+"_") {
+					rules[m.Package.Path.String()+"."+name] = prefix.Severity
+				}
+			}
+		}
+	}
+	refs := make([]string, 0, len(rules))
+	for ref := range rules {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	queries := make([]regoQuery, 0, len(refs))
+	for _, ref := range refs {
+		q, err := rego.New(rego.Query(ref), rego.Compiler(compiler)).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("prepare %s: %w", ref, err)
+		}
+		queries = append(queries, regoQuery{ruleID: strings.TrimPrefix(ref, "data."), severity: rules[ref], query: q})
+	}
+	return queries, nil
+}
+
+// loadRegoModules parses the *.rego files directly in dir, skipping tests. A
+// missing directory has no policies.
+func loadRegoModules(dir string, modules map[string]*ast.Module) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read policy dir %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".rego" || strings.HasSuffix(name, "_test.rego") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read policy %s: %w", path, err)
+		}
+		m, err := ast.ParseModuleWithOpts(path, string(src), ast.ParserOptions{RegoVersion: ast.RegoV1})
+		if err != nil {
+			// Older conftest policies use Rego v0 syntax.
+			var v0Err error
+			if m, v0Err = ast.ParseModuleWithOpts(path, string(src), ast.ParserOptions{RegoVersion: ast.RegoV0}); v0Err != nil {
+				return fmt.Errorf("parse policy %s: %w", path, err)
+			}
+		}
+		modules[path] = m
+	}
+	return nil
+}
+
+func regoChange(c diff.Change) map[string]any {
+	return map[string]any{"id": c.ID, "action": string(c.Action), "changedPaths": c.ChangedPaths, "current": nullable(c.Current), "desired": nullable(c.Desired)}
+}
+
+// regoFindings maps a rule's value: a set of messages or {"msg", "rule"}
+// objects, or true for boolean rules.
+func regoFindings(resourceID string, q regoQuery, value any) []Finding {
+	var items []any
+	switch v := value.(type) {
+	case []any:
+		items = v
+	case bool:
+		if v {
+			items = []any{q.ruleID}
+		}
+	default:
+		items = []any{v}
+	}
+	out := make([]Finding, 0, len(items))
+	for _, item := range items {
+		f := Finding{ResourceID: resourceID, RuleID: q.ruleID, Severity: q.severity}
+		switch v := item.(type) {
+		case string:
+			f.Message = v
+		case map[string]any:
+			f.Message = fmt.Sprint(v["msg"])
+			if rule, ok := v["rule"].(string); ok && rule != "" {
+				f.RuleID = rule
+			}
+		default:
+			f.Message = fmt.Sprint(v)
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+func writePolicy(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegoEvaluator(t *testing.T) {
+	dir := t.TempDir()
+	// Rego v1 syntax, applied to every profile.
+	writePolicy(t, filepath.Join(dir, "labels.rego"), `package main
+
+deny contains msg if {
+	input.resource.kind == "Deployment"
+	not input.resource.metadata.labels.team
+	msg := sprintf("Deployment %s has no team label", [input.resource.metadata.name])
+}
+
+warn_replicas contains {"msg": "replicas change", "rule": "replica-change"} if {
+	input.change.action == "PATCH"
+	input.change.changedPaths[_] == "spec.replicas"
+}
+`)
+	// Legacy conftest (Rego v0) syntax, only for the strict profile.
+	writePolicy(t, filepath.Join(dir, "strict", "latest.rego"), `package kubernetes.images
+
+violation[msg] {
+	c := input.resource.spec.template.spec.containers[_]
+	endswith(c.image, ":latest")
+	msg := sprintf("container %s uses a latest tag", [c.name])
+}
+`)
+	writePolicy(t, filepath.Join(dir, "labels_test.rego"), "package main\n\nthis is not rego")
+
+	deploy := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"kind":     "Deployment",
+		"metadata": map[string]any{"name": "web"},
+		"spec": map[string]any{"replicas": 3, "template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "web", "image": "web:latest"}},
+		}}},
+	}}
+	changes := []diff.Change{{ID: deploy.ID(), Action: diff.Patch, ChangedPaths: []string{"spec.replicas"}, Desired: deploy.Body}}
+	e := NewRegoEvaluator(dir)
+
+	findings := e.EvaluateChanges([]render.Resource{deploy}, changes, "baseline")
+	if len(findings) != 2 {
+		t.Fatalf("expected two baseline findings, got %+v", findings)
+	}
+	if f := findings[0]; f.RuleID != "main.deny" || f.Severity != SeverityError || f.ResourceID != deploy.ID() || f.Message != "Deployment web has no team label" {
+		t.Fatalf("unexpected deny finding %+v", f)
+	}
+	if f := findings[1]; f.RuleID != "replica-change" || f.Severity != SeverityWarn || f.Message != "replicas change" {
+		t.Fatalf("unexpected warn finding %+v", f)
+	}
+
+	findings = e.Evaluate([]render.Resource{deploy}, "strict")
+	if len(findings) != 2 || findings[0].RuleID != "kubernetes.images.violation" || findings[0].Message != "container web uses a latest tag" {
+		t.Fatalf("expected strict profile policies without change rules, got %+v", findings)
+	}
+}
+
+func TestRegoEvaluatorReportsBrokenPolicies(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, filepath.Join(dir, "broken.rego"), "package main\n\ndeny contains msg if {\n")
+	findings := NewRegoEvaluator(dir).Evaluate([]render.Resource{{APIVersion: "v1", Kind: "ConfigMap", Name: "cm"}}, "")
+	if len(findings) != 1 || findings[0].RuleID != "rego-policy-error" || !strings.Contains(findings[0].Message, "broken.rego") {
+		t.Fatalf("expected a policy error finding, got %+v", findings)
+	}
+	if got := NewRegoEvaluator(filepath.Join(dir, "missing")).Evaluate([]render.Resource{{APIVersion: "v1", Kind: "ConfigMap", Name: "cm"}}, ""); len(got) != 0 {
+		t.Fatalf("expected no findings without policies, got %+v", got)
+	}
+	for _, profile := range []string{"stritc", "../shared"} {
+		got := NewRegoEvaluator(filepath.Join(dir, "missing")).Evaluate(nil, profile)
+		if len(got) != 1 || got[0].RuleID != "rego-policy-error" || !strings.Contains(got[0].Message, profile) {
+			t.Fatalf("expected profile %q to be rejected, got %+v", profile, got)
+		}
+	}
+}
+
+func TestEvaluatorsCombineFindings(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, filepath.Join(dir, "all.rego"), "package main\n\nwarn contains \"seen\" if input.change.action == \"CREATE\"\n")
+	secret := render.Resource{APIVersion: "v1", Kind: "Secret", Namespace: "n", Name: "s"}
+	e := Evaluators{NewBuiltinEvaluator(), NewRegoEvaluator(dir)}
+	findings := e.EvaluateChanges([]render.Resource{secret}, []diff.Change{{ID: secret.ID(), Action: diff.Create}}, "")
+	if len(findings) != 2 || findings[0].RuleID != "review-secret-change" || findings[1].Message != "seen" {
+		t.Fatalf("expected builtin and rego findings, got %+v", findings)
+	}
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/thule/internal/diff"
+	"github.com/example/thule/internal/render"
+)
+
+// baselineCapabilities are the capabilities the baseline standard allows
+// containers to add.
+var baselineCapabilities = map[string]struct{}{
+	"AUDIT_WRITE": {}, "CHOWN": {}, "DAC_OVERRIDE": {}, "FOWNER": {}, "FSETID": {}, "KILL": {}, "MKNOD": {},
+	"NET_BIND_SERVICE": {}, "SETFCAP": {}, "SETGID": {}, "SETPCAP": {}, "SETUID": {}, "SYS_CHROOT": {},
+}
+
+var restrictedCapabilities = map[string]struct{}{"NET_BIND_SERVICE": {}}
+
+// batchKinds run to completion and are not expected to have probes.
+var batchKinds = map[string]struct{}{"Job": {}, "CronJob": {}}
+
+// builtinProfile reports whether profile selects builtin rules, so it needs
+// no Rego profile directory.
+func builtinProfile(profile string) bool {
+	return profile == "baseline" || restrictedProfile(profile)
+}
+
+func restrictedProfile(profile string) bool {
+	return profile == "restricted" || profile == "strict"
+}
+
+// securityFindings runs the security pack, modelled on the Pod Security
+// Standards, on one resource. Rules run for the profile named in parentheses
+// and every stricter one; "strict" is "restricted" plus the
+// ClusterRoleBinding review. Unknown profiles, such as Rego-only profile
+// directories, get the baseline rules.
+//
+//   - privileged-container (ERROR, baseline): a container sets
+//     securityContext.privileged.
+//   - host-namespace (ERROR, baseline): the pod sets hostNetwork, hostPID or
+//     hostIPC.
+//   - host-path-volume (ERROR, baseline): the pod mounts a hostPath volume.
+//   - added-capabilities (ERROR, baseline): a container adds capabilities
+//     outside the baseline default set; under restricted anything but
+//     NET_BIND_SERVICE.
+//   - latest-image-tag (WARN, baseline): an image is untagged or uses :latest
+//     without a digest.
+//   - wildcard-rbac-verbs (WARN, baseline): a Role or ClusterRole grants "*"
+//     verbs.
+//   - run-as-root (ERROR, restricted): a container does not set runAsNonRoot,
+//     directly or through the pod, or runs as UID 0.
+//   - missing-resources (WARN, restricted): a container lacks cpu or memory
+//     requests or a memory limit.
+//   - missing-probes (WARN, restricted): a long-running container has no
+//     readiness or liveness probe.
+func securityFindings(r render.Resource, restricted bool) []Finding {
+	if r.Kind == "Role" || r.Kind == "ClusterRole" {
+		return wildcardVerbFindings(r)
+	}
+	spec, ok := r.PodSpec()
+	if !ok {
+		return nil
+	}
+	findings := []Finding{}
+	add := func(rule string, severity Severity, format string, args ...any) {
+		findings = append(findings, Finding{ResourceID: r.ID(), RuleID: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, field := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if v, _ := spec[field].(bool); v {
+			add("host-namespace", SeverityError, "pod sets %s and shares the node's namespace", field)
+		}
+	}
+	for _, v := range mapsOf(spec["volumes"]) {
+		if _, ok := v["hostPath"]; ok {
+			add("host-path-volume", SeverityError, "volume `%v` mounts a hostPath from the node", v["name"])
+		}
+	}
+	podContext, _ := spec["securityContext"].(map[string]any)
+	allowed := baselineCapabilities
+	if restricted {
+		allowed = restrictedCapabilities
+	}
+	_, batch := batchKinds[r.Kind]
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, c := range mapsOf(spec[field]) {
+			name := c["name"]
+			sc, _ := c["securityContext"].(map[string]any)
+			if v, _ := sc["privileged"].(bool); v {
+				add("privileged-container", SeverityError, "container `%v` runs privileged", name)
+			}
+			if caps := addedCapabilities(sc, allowed); len(caps) > 0 {
+				add("added-capabilities", SeverityError, "container `%v` adds capabilities %s", name, strings.Join(caps, ", "))
+			}
+			if image, ok := c["image"].(string); ok && mutableImage(image) {
+				add("latest-image-tag", SeverityWarn, "container `%v` image %s is not pinned to a tag or digest", name, image)
+			}
+			if !restricted {
+				continue
+			}
+			if reason := runsAsRoot(sc, podContext); reason != "" {
+				add("run-as-root", SeverityError, "container `%v` %s", name, reason)
+			}
+			if field == "ephemeralContainers" {
+				continue
+			}
+			if missing := missingResources(c); len(missing) > 0 {
+				add("missing-resources", SeverityWarn, "container `%v` does not set %s", name, strings.Join(missing, ", "))
+			}
+			if field == "containers" && !batch && c["readinessProbe"] == nil && c["livenessProbe"] == nil {
+				add("missing-probes", SeverityWarn, "container `%v` has no readiness or liveness probe", name)
+			}
+		}
+	}
+	return findings
+}
+
+func wildcardVerbFindings(r render.Resource) []Finding {
+	for i, rule := range mapsOf(r.Body["rules"]) {
+		verbs, _ := rule["verbs"].([]any)
+		for _, v := range verbs {
+			if v == "*" {
+				return []Finding{{ResourceID: r.ID(), RuleID: "wildcard-rbac-verbs", Severity: SeverityWarn, Message: fmt.Sprintf("rules[%d] grants every verb (\"*\"); list the verbs needed", i)}}
+			}
+		}
+	}
+	return nil
+}
+
+func addedCapabilities(sc map[string]any, allowed map[string]struct{}) []string {
+	caps, _ := sc["capabilities"].(map[string]any)
+	added, _ := caps["add"].([]any)
+	out := []string{}
+	for _, c := range added {
+		name := strings.TrimPrefix(fmt.Sprint(c), "CAP_")
+		if _, ok := allowed[name]; !ok {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// mutableImage reports images without a digest whose tag is missing or
+// "latest".
+func mutableImage(image string) bool {
+	ref := diff.ParseImageRef(image)
+	return ref.Digest == "" && (ref.Tag == "" || ref.Tag == "latest")
+}
+
+// runsAsRoot explains why a container may run as root; container settings
+// override the pod's.
+func runsAsRoot(container, pod map[string]any) string {
+	setting := func(key string) any {
+		if v, ok := container[key]; ok {
+			return v
+		}
+		return pod[key]
+	}
+	if uid := setting("runAsUser"); uid != nil && fmt.Sprint(uid) == "0" {
+		return "runs as UID 0"
+	}
+	if nonRoot, _ := setting("runAsNonRoot").(bool); !nonRoot {
+		return "does not set runAsNonRoot: true"
+	}
+	return ""
+}
+
+func missingResources(c map[string]any) []string {
+	resources, _ := c["resources"].(map[string]any)
+	missing := []string{}
+	for _, field := range []string{"requests.cpu", "requests.memory", "limits.memory"} {
+		section, key, _ := strings.Cut(field, ".")
+		values, _ := resources[section].(map[string]any)
+		if _, ok := values[key]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+func mapsOf(v any) []map[string]any {
+	items, _ := v.([]any)
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
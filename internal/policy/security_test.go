@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestBuiltinEvaluatorSecurityPack(t *testing.T) {
+	deployment := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"hostNetwork": true,
+			"volumes":     []any{map[string]any{"name": "docker", "hostPath": map[string]any{"path": "/var/run/docker.sock"}}},
+			"containers": []any{
+				map[string]any{"name": "app", "image": "nginx", "securityContext": map[string]any{
+					"privileged":   true,
+					"capabilities": map[string]any{"add": []any{"NET_ADMIN", "CHOWN"}},
+				}},
+				map[string]any{"name": "sidecar", "image": "registry.example.com:5000/proxy:1.2.3",
+					"securityContext": map[string]any{"runAsNonRoot": true, "capabilities": map[string]any{"add": []any{"CHOWN"}}},
+					"resources":       map[string]any{"requests": map[string]any{"cpu": "100m", "memory": "64Mi"}, "limits": map[string]any{"memory": "64Mi"}},
+					"readinessProbe":  map[string]any{"httpGet": map[string]any{"port": 8080}},
+				},
+			},
+		}}},
+	}}
+	job := render.Resource{APIVersion: "batch/v1", Kind: "Job", Namespace: "n", Name: "migrate", Body: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"securityContext": map[string]any{"runAsNonRoot": true},
+			"containers": []any{map[string]any{"name": "migrate", "image": "migrate:latest", "securityContext": map[string]any{"runAsUser": 0},
+				"resources": map[string]any{"requests": map[string]any{"cpu": "1", "memory": "1Gi"}, "limits": map[string]any{"memory": "1Gi"}}}},
+		}}},
+	}}
+	role := render.Resource{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "admin", Body: map[string]any{
+		"rules": []any{map[string]any{"apiGroups": []any{""}, "resources": []any{"pods"}, "verbs": []any{"*"}}},
+	}}
+	resources := []render.Resource{deployment, job, role}
+
+	ruleIDs := func(findings []Finding) string {
+		out := []string{}
+		for _, f := range findings {
+			out = append(out, f.ResourceID+" "+f.RuleID)
+		}
+		sort.Strings(out)
+		return strings.Join(out, "\n")
+	}
+	baseline := NewBuiltinEvaluator().Evaluate(resources, "baseline")
+	want := strings.Join([]string{
+		"apps/v1|Deployment|n|web added-capabilities",
+		"apps/v1|Deployment|n|web host-namespace",
+		"apps/v1|Deployment|n|web host-path-volume",
+		"apps/v1|Deployment|n|web latest-image-tag",
+		"apps/v1|Deployment|n|web privileged-container",
+		"batch/v1|Job|n|migrate latest-image-tag",
+		"rbac.authorization.k8s.io/v1|ClusterRole|_cluster|admin wildcard-rbac-verbs",
+	}, "\n")
+	if got := ruleIDs(baseline); got != want {
+		t.Fatalf("unexpected baseline findings:\n%s", got)
+	}
+	for _, f := range baseline {
+		if f.RuleID == "added-capabilities" && f.Message != "container `app` adds capabilities NET_ADMIN" {
+			t.Fatalf("baseline allows CHOWN: %+v", f)
+		}
+	}
+
+	// Without a profile the baseline rules only warn.
+	defaulted := NewBuiltinEvaluator().Evaluate(resources, "")
+	if ruleIDs(defaulted) != want {
+		t.Fatalf("unexpected default findings:\n%s", ruleIDs(defaulted))
+	}
+	for _, f := range defaulted {
+		if f.Severity != SeverityWarn {
+			t.Fatalf("expected only warnings without a profile, got %+v", f)
+		}
+	}
+
+	restricted := NewBuiltinEvaluator().Evaluate(resources, "restricted")
+	got := []string{}
+	for _, f := range restricted {
+		if f.RuleID == "added-capabilities" || f.RuleID == "run-as-root" || f.RuleID == "missing-resources" || f.RuleID == "missing-probes" {
+			got = append(got, f.ResourceID+" "+f.RuleID+" "+string(f.Severity)+": "+f.Message)
+		}
+	}
+	want = strings.Join([]string{
+		"apps/v1|Deployment|n|web added-capabilities ERROR: container `app` adds capabilities CHOWN, NET_ADMIN",
+		"apps/v1|Deployment|n|web run-as-root ERROR: container `app` does not set runAsNonRoot: true",
+		"apps/v1|Deployment|n|web missing-resources WARN: container `app` does not set requests.cpu, requests.memory, limits.memory",
+		"apps/v1|Deployment|n|web missing-probes WARN: container `app` has no readiness or liveness probe",
+		"apps/v1|Deployment|n|web added-capabilities ERROR: container `sidecar` adds capabilities CHOWN",
+		"batch/v1|Job|n|migrate run-as-root ERROR: container `migrate` runs as UID 0",
+	}, "\n")
+	if strings.Join(got, "\n") != want {
+		t.Fatalf("unexpected restricted findings:\n%s", strings.Join(got, "\n"))
+	}
+	if strict := NewBuiltinEvaluator().Evaluate(resources, "strict"); ruleIDs(strict) != ruleIDs(restricted) {
+		t.Fatalf("strict should include the restricted rules:\n%s", ruleIDs(strict))
+	}
+}
@@ -0,0 +1,234 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/example/thule/internal/render"
+)
+
+// Waiver annotations. WaiveAnnotation lists the rule IDs (comma separated)
+// waived for the annotated resource; the reason is mandatory and the expiry
+// date (YYYY-MM-DD, inclusive) optional.
+const (
+	WaiveAnnotation        = "thule.io/waive"
+	WaiveReasonAnnotation  = "thule.io/waive-reason"
+	WaiveExpiresAnnotation = "thule.io/waive-expires"
+)
+
+const waiverDateLayout = "2006-01-02"
+
+// Waiver records why a finding is suppressed.
+type Waiver struct {
+	// Source is "annotation" or the exceptions file the waiver comes from.
+	Source  string
+	Reason  string
+	Expires string
+}
+
+// Exception is an entry of the repository exceptions file, waiving a rule
+// for the resources its selector matches.
+type Exception struct {
+	Rule     string            `json:"rule"`
+	Selector ExceptionSelector `json:"selector"`
+	Reason   string            `json:"reason"`
+	Expires  string            `json:"expires,omitempty"`
+}
+
+// ExceptionSelector matches resources; empty fields match everything and
+// project, kind, namespace and name accept path.Match globs.
+type ExceptionSelector struct {
+	Project       string                `json:"project,omitempty"`
+	Kind          string                `json:"kind,omitempty"`
+	Namespace     string                `json:"namespace,omitempty"`
+	Name          string                `json:"name,omitempty"`
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+type exceptionsFile struct {
+	Exceptions []Exception `json:"exceptions"`
+}
+
+// LoadExceptions reads an exceptions file:
+//
+//	exceptions:
+//	  - rule: host-path-volume
+//	    selector: {project: monitoring, kind: DaemonSet, name: node-exporter}
+//	    reason: node-exporter reads host metrics
+//	    expires: "2026-12-31"
+//
+// A missing file has no exceptions. Entries without a rule or reason, or
+// with an invalid expiry date, are errors.
+func LoadExceptions(filePath string) ([]Exception, error) {
+	content, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read policy exceptions: %w", err)
+	}
+	var generic map[string]any
+	if err := yaml.Unmarshal(content, &generic); err != nil {
+		return nil, fmt.Errorf("parse policy exceptions %s: %w", filePath, err)
+	}
+	var file exceptionsFile
+	if err := decodeBody(generic, &file); err != nil {
+		return nil, fmt.Errorf("parse policy exceptions %s: %w", filePath, err)
+	}
+	for i, e := range file.Exceptions {
+		if e.Rule == "" || strings.TrimSpace(e.Reason) == "" {
+			return nil, fmt.Errorf("policy exceptions %s: exceptions[%d] needs a rule and a reason", filePath, i)
+		}
+		if e.Expires != "" {
+			if file.Exceptions[i].Expires, err = waiverDate(e.Expires); err != nil {
+				return nil, fmt.Errorf("policy exceptions %s: exceptions[%d] expires %q is not a YYYY-MM-DD date", filePath, i, e.Expires)
+			}
+		}
+		if e.Selector.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(e.Selector.LabelSelector); err != nil {
+				return nil, fmt.Errorf("policy exceptions %s: exceptions[%d] labelSelector: %w", filePath, i, err)
+			}
+		}
+	}
+	return file.Exceptions, nil
+}
+
+// ApplyWaivers sets Waiver on the findings of project waived by an
+// annotation of their resource or by an exception from source. Waived
+// findings stay in the result so reports can still list them. Malformed
+// annotation waivers are reported as invalid-waiver and expired waivers that
+// would have matched as expired-waiver (both WARN); neither waives anything.
+func ApplyWaivers(project string, findings []Finding, resources []render.Resource, exceptions []Exception, source string, now time.Time) []Finding {
+	byID := map[string]render.Resource{}
+	for _, r := range resources {
+		byID[r.ID()] = r
+	}
+	out := make([]Finding, 0, len(findings))
+	reported := map[string]struct{}{}
+	report := func(f Finding) {
+		key := f.ResourceID + "|" + f.RuleID + "|" + f.Message
+		if _, ok := reported[key]; !ok {
+			reported[key] = struct{}{}
+			out = append(out, f)
+		}
+	}
+	for _, f := range findings {
+		r, ok := byID[f.ResourceID]
+		if !ok {
+			r = resourceFromID(f.ResourceID)
+		}
+		candidates := []Waiver{}
+		if w, err := annotationWaiver(r, f.RuleID); err != nil {
+			report(Finding{ResourceID: f.ResourceID, RuleID: "invalid-waiver", Severity: SeverityWarn, Message: err.Error()})
+		} else if w != nil {
+			candidates = append(candidates, *w)
+		}
+		for _, e := range exceptions {
+			if e.Rule == f.RuleID && e.Selector.matches(project, r) {
+				candidates = append(candidates, Waiver{Source: source, Reason: e.Reason, Expires: e.Expires})
+			}
+		}
+		for _, w := range candidates {
+			if !waiverExpired(w, now) {
+				f.Waiver = &w
+				break
+			}
+			report(Finding{ResourceID: f.ResourceID, RuleID: "expired-waiver", Severity: SeverityWarn, Message: fmt.Sprintf("waiver of %s from %s expired on %s", f.RuleID, w.Source, w.Expires)})
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// annotationWaiver returns the waiver r's annotations grant for rule, if
+// any.
+func annotationWaiver(r render.Resource, rule string) (*Waiver, error) {
+	meta, _ := r.Body["metadata"].(map[string]any)
+	annotations, _ := meta["annotations"].(map[string]any)
+	rules, _ := annotations[WaiveAnnotation].(string)
+	waived := false
+	for _, id := range strings.Split(rules, ",") {
+		if strings.TrimSpace(id) == rule {
+			waived = true
+		}
+	}
+	if !waived {
+		return nil, nil
+	}
+	reason, _ := annotations[WaiveReasonAnnotation].(string)
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%s needs a %s annotation; %s is not waived", WaiveAnnotation, WaiveReasonAnnotation, rule)
+	}
+	w := &Waiver{Source: "annotation", Reason: reason}
+	switch v := annotations[WaiveExpiresAnnotation].(type) {
+	case nil:
+	case time.Time:
+		// Unquoted YAML dates decode to time.Time.
+		w.Expires = v.Format(waiverDateLayout)
+	default:
+		var err error
+		if w.Expires, err = waiverDate(fmt.Sprint(v)); err != nil {
+			return nil, fmt.Errorf("%s %q is not a YYYY-MM-DD date; %s is not waived", WaiveExpiresAnnotation, fmt.Sprint(v), rule)
+		}
+	}
+	return w, nil
+}
+
+// waiverDate normalizes an expiry date to YYYY-MM-DD. Dates decoded from
+// unquoted YAML arrive as RFC 3339 timestamps.
+func waiverDate(s string) (string, error) {
+	if _, err := time.Parse(waiverDateLayout, s); err == nil {
+		return s, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(waiverDateLayout), nil
+}
+
+// waiverExpired reports whether now is past the waiver's expiry day (UTC).
+func waiverExpired(w Waiver, now time.Time) bool {
+	if w.Expires == "" {
+		return false
+	}
+	day, err := time.Parse(waiverDateLayout, w.Expires)
+	return err != nil || !now.Before(day.AddDate(0, 0, 1))
+}
+
+func (s ExceptionSelector) matches(project string, r render.Resource) bool {
+	for _, field := range []struct{ pattern, value string }{
+		{s.Project, project},
+		{s.Kind, r.Kind},
+		{s.Namespace, r.Namespace},
+		{s.Name, r.Name},
+	} {
+		if field.pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(field.pattern, field.value); !ok {
+			return false
+		}
+	}
+	return s.LabelSelector == nil || selectorMatches(s.LabelSelector, bodyLabels(r.Body))
+}
+
+// resourceFromID recovers the identity of a resource that is not in the
+// render, e.g. a live object planned for deletion.
+func resourceFromID(id string) render.Resource {
+	parts := strings.Split(id, "|")
+	if len(parts) != 4 {
+		return render.Resource{}
+	}
+	ns := parts[2]
+	if ns == "_cluster" {
+		ns = ""
+	}
+	return render.Resource{APIVersion: parts[0], Kind: parts[1], Namespace: ns, Name: parts[3]}
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestApplyWaivers(t *testing.T) {
+	annotated := func(name string, annotations map[string]any) render.Resource {
+		return render.Resource{APIVersion: "apps/v1", Kind: "DaemonSet", Namespace: "monitoring", Name: name, Body: map[string]any{
+			"metadata": map[string]any{"name": name, "labels": map[string]any{"team": "obs"}, "annotations": annotations},
+		}}
+	}
+	exporter := annotated("node-exporter", map[string]any{WaiveAnnotation: "host-namespace, privileged-container", WaiveReasonAnnotation: "reads host metrics", WaiveExpiresAnnotation: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)})
+	agent := annotated("agent", map[string]any{WaiveAnnotation: "privileged-container"})
+	old := annotated("old", map[string]any{WaiveAnnotation: "privileged-container", WaiveReasonAnnotation: "legacy", WaiveExpiresAnnotation: "2026-01-31"})
+	findings := []Finding{
+		{ResourceID: exporter.ID(), RuleID: "host-namespace", Severity: SeverityError},
+		{ResourceID: exporter.ID(), RuleID: "host-path-volume", Severity: SeverityError},
+		{ResourceID: agent.ID(), RuleID: "privileged-container", Severity: SeverityError},
+		{ResourceID: old.ID(), RuleID: "privileged-container", Severity: SeverityError},
+		{ResourceID: "apps/v1|DaemonSet|monitoring|gone", RuleID: "host-path-volume", Severity: SeverityError},
+	}
+	exceptions := []Exception{
+		{Rule: "host-path-volume", Selector: ExceptionSelector{Project: "mon*", Kind: "DaemonSet", Name: "node-*", LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "obs"}}}, Reason: "node agents"},
+		{Rule: "host-path-volume", Selector: ExceptionSelector{Name: "gone"}, Reason: "being removed", Expires: "2026-10-01"},
+	}
+	got := ApplyWaivers("monitoring", findings, []render.Resource{exporter, agent, old}, exceptions, "exceptions.yaml", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	summary := []string{}
+	for _, f := range got {
+		line := f.RuleID + " " + f.ResourceID
+		if f.Waiver != nil {
+			line += " waived by " + f.Waiver.Source + ": " + f.Waiver.Reason + " until " + f.Waiver.Expires
+		}
+		summary = append(summary, line)
+	}
+	want := []string{
+		"host-namespace apps/v1|DaemonSet|monitoring|node-exporter waived by annotation: reads host metrics until 2026-12-31",
+		"host-path-volume apps/v1|DaemonSet|monitoring|node-exporter waived by exceptions.yaml: node agents until ",
+		"invalid-waiver apps/v1|DaemonSet|monitoring|agent",
+		"privileged-container apps/v1|DaemonSet|monitoring|agent",
+		"expired-waiver apps/v1|DaemonSet|monitoring|old",
+		"privileged-container apps/v1|DaemonSet|monitoring|old",
+		"expired-waiver apps/v1|DaemonSet|monitoring|gone",
+		"host-path-volume apps/v1|DaemonSet|monitoring|gone",
+	}
+	if strings.Join(summary, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected waivers:\n%s", strings.Join(summary, "\n"))
+	}
+	if !strings.Contains(got[2].Message, WaiveReasonAnnotation) {
+		t.Fatalf("invalid waiver should name the missing reason: %+v", got[2])
+	}
+}
+
+func TestLoadExceptions(t *testing.T) {
+	dir := t.TempDir()
+	if exceptions, err := LoadExceptions(filepath.Join(dir, "missing.yaml")); err != nil || exceptions != nil {
+		t.Fatalf("missing file should have no exceptions: %v %v", exceptions, err)
+	}
+	path := filepath.Join(dir, "exceptions.yaml")
+	content := "exceptions:\n- rule: host-path-volume\n  selector:\n    kind: DaemonSet\n    labelSelector:\n      matchLabels:\n        team: obs\n  reason: node agents\n  expires: 2026-12-31\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exceptions, err := LoadExceptions(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(exceptions) != 1 || exceptions[0].Expires != "2026-12-31" || exceptions[0].Selector.LabelSelector.MatchLabels["team"] != "obs" {
+		t.Fatalf("unexpected exceptions %+v", exceptions)
+	}
+	if err := os.WriteFile(path, []byte("exceptions:\n- rule: host-path-volume\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadExceptions(path); err == nil || !strings.Contains(err.Error(), "reason") {
+		t.Fatalf("expected missing reason error, got %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+package render
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fluxcd/pkg/envsubst"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// Labels helm-controller and kustomize-controller stamp on the objects they
+// manage.
+const (
+	HelmReleaseNameLabel        = "helm.toolkit.fluxcd.io/name"
+	HelmReleaseNamespaceLabel   = "helm.toolkit.fluxcd.io/namespace"
+	KustomizationNameLabel      = "kustomize.toolkit.fluxcd.io/name"
+	KustomizationNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+
+	substituteDisabledKey = "kustomize.toolkit.fluxcd.io/substitute"
+)
+
+var varsubPattern = regexp.MustCompile(`^[_[:alpha:]][_[:alpha:][:digit:]]*$`)
+
+// expandHelmReleases templates the charts of HelmReleases whose chart lives in
+// the repository (GitRepository sources). HelmReleases backed by Helm or OCI
+// repositories are left as plain custom resources. kubeVersion, if set, is the
+// cluster version Helm .Capabilities report.
+func expandHelmReleases(resources []Resource, repoRoot, defaultNamespace, kubeVersion string) ([]Resource, error) {
+	out := []Resource{}
+	for _, r := range resources {
+		if r.Kind != "HelmRelease" || !strings.HasPrefix(r.APIVersion, "helm.toolkit.fluxcd.io/") {
+			continue
+		}
+		expanded, err := expandHelmRelease(r, resources, repoRoot, defaultNamespace, kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("expand HelmRelease %s: %w", r.ID(), err)
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+func expandHelmRelease(hr Resource, resources []Resource, repoRoot, defaultNamespace, kubeVersion string) ([]Resource, error) {
+	spec, _ := hr.Body["spec"].(map[string]any)
+	chart, _ := spec["chart"].(map[string]any)
+	chartSpec, _ := chart["spec"].(map[string]any)
+	chartRef, _ := chartSpec["chart"].(string)
+	sourceRef, _ := chartSpec["sourceRef"].(map[string]any)
+	if kind, _ := sourceRef["kind"].(string); kind != "GitRepository" || chartRef == "" {
+		return nil, nil
+	}
+	chartPath := filepath.Join(repoRoot, filepath.FromSlash(chartRef))
+	if _, err := os.Stat(filepath.Join(chartPath, chartutil.ChartfileName)); err != nil {
+		// The GitRepository points at a different repository or revision.
+		return nil, nil
+	}
+
+	namespace := hr.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	targetNamespace, _ := spec["targetNamespace"].(string)
+	releaseName, _ := spec["releaseName"].(string)
+	if releaseName == "" {
+		releaseName = hr.Name
+		if targetNamespace != "" {
+			releaseName = targetNamespace + "-" + hr.Name
+		}
+	}
+	if targetNamespace == "" {
+		targetNamespace = namespace
+	}
+
+	vals, inputs, unresolved, err := helmReleaseValues(spec, resources, namespace)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := templateChart(chartPath, helmTemplateOptions{
+		ReleaseName: releaseName,
+		Namespace:   targetNamespace,
+		Values:      vals,
+		KubeVersion: kubeVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hr.SourcePath != "" {
+		inputs = append(inputs, hr.SourcePath)
+	}
+	for i := range rendered {
+		setLabel(rendered[i].Body, HelmReleaseNameLabel, hr.Name)
+		setLabel(rendered[i].Body, HelmReleaseNamespaceLabel, namespace)
+		rendered[i].InputPaths = append(append([]string{}, rendered[i].InputPaths...), inputs...)
+		rendered[i].Parent = hr.ID()
+		rendered[i].Unresolved = unresolved
+	}
+	return rendered, nil
+}
+
+// expandKustomizations builds the spec.path of Flux Kustomizations sourced
+// from the repository (GitRepository), applying the same targetNamespace,
+// patches, images and postBuild substitutions as kustomize-controller.
+func expandKustomizations(resources []Resource, repoRoot, defaultNamespace string) ([]Resource, error) {
+	out := []Resource{}
+	for _, r := range resources {
+		if r.Kind != "Kustomization" || !strings.HasPrefix(r.APIVersion, "kustomize.toolkit.fluxcd.io/") {
+			continue
+		}
+		expanded, err := expandKustomization(r, resources, repoRoot, defaultNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("expand Kustomization %s: %w", r.ID(), err)
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+func expandKustomization(ks Resource, resources []Resource, repoRoot, defaultNamespace string) ([]Resource, error) {
+	spec, _ := ks.Body["spec"].(map[string]any)
+	sourceRef, _ := spec["sourceRef"].(map[string]any)
+	if kind, _ := sourceRef["kind"].(string); kind != "GitRepository" {
+		return nil, nil
+	}
+	path, _ := spec["path"].(string)
+	dir := filepath.Join(repoRoot, filepath.FromSlash(path))
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		// The GitRepository points at a different repository or revision.
+		return nil, nil
+	}
+	kustomizationFile, err := findKustomizationFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if kustomizationFile == "" {
+		kustomizationFile = filepath.Join(dir, "kustomization.yaml")
+	}
+
+	namespace := ks.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	vars, inputs, unresolved, err := postBuildVariables(spec, resources, namespace)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := buildKustomization(dir, kustomizationFile, func(doc map[string]any) {
+		if tns, _ := spec["targetNamespace"].(string); tns != "" {
+			doc["namespace"] = tns
+		}
+		for _, field := range []string{"patches", "images"} {
+			if extra, ok := spec[field].([]any); ok && len(extra) > 0 {
+				existing, _ := doc[field].([]any)
+				doc[field] = append(existing, extra...)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ks.SourcePath != "" {
+		inputs = append(inputs, ks.SourcePath)
+	}
+
+	out := make([]Resource, 0, len(rendered))
+	for _, r := range rendered {
+		if len(vars) > 0 && !substitutionDisabled(r.Body) {
+			body, err := substituteVariables(r.Body, vars)
+			if err != nil {
+				return nil, fmt.Errorf("postBuild substitution for %s: %w", r.ID(), err)
+			}
+			substituted, ok := resourceFromDocument(body, r.SourcePath)
+			if !ok {
+				return nil, fmt.Errorf("postBuild substitution for %s removed its identity", r.ID())
+			}
+			substituted.InputPaths = r.InputPaths
+			r = substituted
+		}
+		setLabel(r.Body, KustomizationNameLabel, ks.Name)
+		setLabel(r.Body, KustomizationNamespaceLabel, namespace)
+		r.InputPaths = append(append([]string{}, r.InputPaths...), inputs...)
+		r.Parent = ks.ID()
+		r.Unresolved = unresolved
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// postBuildVariables loads postBuild.substituteFrom ConfigMaps/Secrets in
+// order and then postBuild.substitute, which takes precedence. Non-optional
+// sources missing from the repository are returned as unresolved.
+func postBuildVariables(spec map[string]any, resources []Resource, namespace string) (map[string]string, []string, []string, error) {
+	postBuild, _ := spec["postBuild"].(map[string]any)
+	vars := map[string]string{}
+	inputs := []string{}
+	var unresolved []string
+	refs, _ := postBuild["substituteFrom"].([]any)
+	for _, raw := range refs {
+		ref, _ := raw.(map[string]any)
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		optional, _ := ref["optional"].(bool)
+		src, ok := findResource(resources, kind, namespace, name)
+		if !ok {
+			// Objects that only exist in the cluster cannot be resolved
+			// offline; the plan reports them.
+			if !optional {
+				unresolved = append(unresolved, fmt.Sprintf("substituteFrom %s/%s", kind, name))
+			}
+			continue
+		}
+		keys := map[string]struct{}{}
+		for _, field := range []string{"data", "stringData"} {
+			data, _ := src.Body[field].(map[string]any)
+			for k := range data {
+				keys[k] = struct{}{}
+			}
+		}
+		for k := range keys {
+			v, _, err := resourceDataValue(src, k)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			vars[k] = strings.ReplaceAll(v, "\n", "")
+		}
+		if src.SourcePath != "" {
+			inputs = append(inputs, src.SourcePath)
+		}
+	}
+	inline, _ := postBuild["substitute"].(map[string]any)
+	for k, v := range inline {
+		vars[k] = strings.ReplaceAll(fmt.Sprint(v), "\n", "")
+	}
+	for k := range vars {
+		if !varsubPattern.MatchString(k) {
+			return nil, nil, nil, fmt.Errorf("%q var name is invalid, must match %q", k, varsubPattern.String())
+		}
+	}
+	return vars, inputs, unresolved, nil
+}
+
+func substitutionDisabled(body map[string]any) bool {
+	meta, _ := body["metadata"].(map[string]any)
+	for _, field := range []string{"labels", "annotations"} {
+		m, _ := meta[field].(map[string]any)
+		if v, _ := m[substituteDisabledKey].(string); v == "disabled" {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteVariables runs bash-style ${var} substitution over the YAML form
+// of body. Undefined variables without defaults become empty strings.
+func substituteVariables(body map[string]any, vars map[string]string) (map[string]any, error) {
+	content, err := yaml.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	output, err := envsubst.Eval(string(content), func(s string) (string, bool) {
+		return vars[s], true
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	if err := yaml.Unmarshal([]byte(output), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func setLabel(body map[string]any, key, value string) {
+	meta, _ := body["metadata"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		body["metadata"] = meta
+	}
+	labels, _ := meta["labels"].(map[string]any)
+	if labels == nil {
+		labels = map[string]any{}
+		meta["labels"] = labels
+	}
+	labels[key] = value
+}
+
+// helmReleaseValues merges spec.valuesFrom (in order) and then spec.values,
+// as helm-controller does. ConfigMaps and Secrets are looked up among the
+// repository resources and their source files returned as inputs; the
+// non-optional ones not defined there are returned as unresolved.
+func helmReleaseValues(spec map[string]any, resources []Resource, namespace string) (map[string]any, []string, []string, error) {
+	vals := map[string]any{}
+	inputs := []string{}
+	var unresolved []string
+	refs, _ := spec["valuesFrom"].([]any)
+	for _, raw := range refs {
+		ref, _ := raw.(map[string]any)
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		key, _ := ref["valuesKey"].(string)
+		if key == "" {
+			key = chartutil.ValuesfileName
+		}
+		targetPath, _ := ref["targetPath"].(string)
+		optional, _ := ref["optional"].(bool)
+
+		src, ok := findResource(resources, kind, namespace, name)
+		if !ok {
+			// Objects that only exist in the cluster cannot be resolved
+			// offline; the plan reports them.
+			if !optional {
+				unresolved = append(unresolved, fmt.Sprintf("valuesFrom %s/%s", kind, name))
+			}
+			continue
+		}
+		data, ok, err := resourceDataValue(src, key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !ok {
+			if optional {
+				continue
+			}
+			return nil, nil, nil, fmt.Errorf("valuesFrom %s/%s has no key %q", kind, name, key)
+		}
+		if src.SourcePath != "" {
+			inputs = append(inputs, src.SourcePath)
+		}
+		if targetPath != "" {
+			if err := strvals.ParseIntoString(targetPath+"="+data, vals); err != nil {
+				return nil, nil, nil, fmt.Errorf("valuesFrom %s/%s targetPath %q: %w", kind, name, targetPath, err)
+			}
+			continue
+		}
+		parsed := map[string]any{}
+		if err := yaml.Unmarshal([]byte(data), &parsed); err != nil {
+			return nil, nil, nil, fmt.Errorf("valuesFrom %s/%s key %q: %w", kind, name, key, err)
+		}
+		vals = mergeValues(vals, parsed)
+	}
+	if inline, ok := spec["values"].(map[string]any); ok {
+		vals = mergeValues(vals, inline)
+	}
+	return vals, inputs, unresolved, nil
+}
+
+func findResource(resources []Resource, kind, namespace, name string) (Resource, bool) {
+	for _, r := range resources {
+		if r.Kind == kind && r.Name == name && (r.Namespace == "" || r.Namespace == namespace) {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// resourceDataValue reads key from a ConfigMap's data or a Secret's
+// stringData/data (base64-decoded).
+func resourceDataValue(r Resource, key string) (string, bool, error) {
+	if r.Kind == "Secret" {
+		if sd, ok := r.Body["stringData"].(map[string]any); ok {
+			if v, ok := sd[key]; ok {
+				return fmt.Sprint(v), true, nil
+			}
+		}
+		data, _ := r.Body["data"].(map[string]any)
+		v, ok := data[key].(string)
+		if !ok {
+			return "", false, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return "", false, fmt.Errorf("decode Secret %s key %q: %w", r.Name, key, err)
+		}
+		return string(decoded), true, nil
+	}
+	data, _ := r.Body["data"].(map[string]any)
+	v, ok := data[key]
+	if !ok {
+		return "", false, nil
+	}
+	return fmt.Sprint(v), true, nil
+}
+
+func findRepoRoot(projectRoot string) string {
+	abs, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return projectRoot
+	}
+	for dir := abs; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return projectRoot
+		}
+		dir = parent
+	}
+}
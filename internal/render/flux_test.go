@@ -0,0 +1,317 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+const testHelmRelease = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: web
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: ./charts/web
+      sourceRef:
+        kind: GitRepository
+        name: flux-system
+        namespace: flux-system
+  valuesFrom:
+    - kind: ConfigMap
+      name: web-values
+    - kind: Secret
+      name: web-image
+      valuesKey: tag
+      targetPath: imageTag
+    - kind: ConfigMap
+      name: absent
+      optional: true
+  values:
+    replicas: 4
+`
+
+func writeFluxRepo(t *testing.T, release string) string {
+	t.Helper()
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"charts/web/Chart.yaml":            "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/values.yaml":           "replicas: 1\nimage: nginx\nimageTag: \"1.0\"\nlogLevel: info\n",
+		"charts/web/templates/deploy.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: {{ .Release.Name }}\n  namespace: {{ .Release.Namespace }}\n  annotations:\n    log: {{ .Values.logLevel }}\nspec:\n  replicas: {{ .Values.replicas }}\n  template:\n    spec:\n      containers:\n        - name: web\n          image: {{ .Values.image }}:{{ .Values.imageTag }}\n",
+		"apps/web/release.yaml":            release,
+		"apps/web/values.yaml":             "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-values\n  namespace: apps\ndata:\n  values.yaml: |\n    replicas: 2\n    logLevel: debug\n",
+		"apps/web/secret.yaml":             "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web-image\n  namespace: apps\ndata:\n  tag: Mi4w\n",
+	})
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRenderProjectFluxExpandsHelmRelease(t *testing.T) {
+	repo := writeFluxRepo(t, testHelmRelease)
+	cfg := thuleconfig.Config{Namespace: "apps", Render: thuleconfig.Render{Mode: "flux", Path: "."}}
+	out, err := RenderProject(filepath.Join(repo, "apps", "web"), cfg)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	var deploy *Resource
+	kinds := map[string]bool{}
+	for i := range out {
+		kinds[out[i].Kind] = true
+		if out[i].Kind == "Deployment" {
+			deploy = &out[i]
+		}
+	}
+	if !kinds["HelmRelease"] || deploy == nil {
+		t.Fatalf("expected HelmRelease and expanded Deployment, got %+v", out)
+	}
+	if deploy.Name != "web" || deploy.Namespace != "apps" {
+		t.Fatalf("unexpected release naming: %+v", deploy)
+	}
+	spec, _ := deploy.Body["spec"].(map[string]any)
+	if spec["replicas"] != 4 {
+		t.Fatalf("expected inline values to win, got %+v", spec)
+	}
+	meta, _ := deploy.Body["metadata"].(map[string]any)
+	anns, _ := meta["annotations"].(map[string]any)
+	if anns["log"] != "debug" {
+		t.Fatalf("expected valuesFrom ConfigMap values, got %+v", anns)
+	}
+	containers, _ := spec["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)
+	if image := containers[0].(map[string]any)["image"]; image != "nginx:2.0" {
+		t.Fatalf("expected Secret targetPath value, got %v", image)
+	}
+	labels, _ := meta["labels"].(map[string]any)
+	if labels[HelmReleaseNameLabel] != "web" || labels[HelmReleaseNamespaceLabel] != "apps" {
+		t.Fatalf("expected helm-controller labels, got %+v", labels)
+	}
+	if want := filepath.Join(repo, "charts", "web", "templates", "deploy.yaml"); deploy.SourcePath != want {
+		t.Fatalf("expected chart template source, got %s", deploy.SourcePath)
+	}
+	inputs := map[string]bool{}
+	for _, p := range deploy.InputPaths {
+		inputs[p] = true
+	}
+	for _, want := range []string{"release.yaml", "values.yaml", "secret.yaml"} {
+		if !inputs[filepath.Join(repo, "apps", "web", want)] {
+			t.Fatalf("expected %s in input paths, got %+v", want, deploy.InputPaths)
+		}
+	}
+}
+
+func TestRenderProjectFluxHelmReleaseTargetNamespace(t *testing.T) {
+	release := "apiVersion: helm.toolkit.fluxcd.io/v2\nkind: HelmRelease\nmetadata:\n  name: web\nspec:\n  targetNamespace: prod\n  chart:\n    spec:\n      chart: charts/web\n      sourceRef:\n        kind: GitRepository\n        name: flux-system\n"
+	repo := writeFluxRepo(t, release)
+	cfg := thuleconfig.Config{Namespace: "apps", Render: thuleconfig.Render{Mode: "flux", Path: "release.yaml"}}
+	out, err := RenderProjectWithOptions(filepath.Join(repo, "apps", "web"), cfg, Options{RepoRoot: repo})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, r := range out {
+		if r.Kind == "Deployment" {
+			if r.Name != "prod-web" || r.Namespace != "prod" {
+				t.Fatalf("expected targetNamespace release naming, got %+v", r)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected expanded deployment, got %+v", out)
+}
+
+func TestRenderProjectFluxSkipsRemoteCharts(t *testing.T) {
+	release := "apiVersion: helm.toolkit.fluxcd.io/v2\nkind: HelmRelease\nmetadata:\n  name: web\nspec:\n  chart:\n    spec:\n      chart: podinfo\n      sourceRef:\n        kind: HelmRepository\n        name: podinfo\n"
+	repo := writeFluxRepo(t, release)
+	out, err := RenderProject(filepath.Join(repo, "apps", "web"), thuleconfig.Config{Render: thuleconfig.Render{Mode: "flux", Path: "release.yaml"}})
+	if err != nil || len(out) != 1 || out[0].Kind != "HelmRelease" {
+		t.Fatalf("expected only the HelmRelease, got %v %+v", err, out)
+	}
+}
+
+func TestRenderProjectFluxHelmReleaseValuesErrors(t *testing.T) {
+	tests := []string{
+		"  valuesFrom:\n    - kind: ConfigMap\n      name: web-values\n      valuesKey: nope\n",
+		"  valuesFrom:\n    - kind: Secret\n      name: broken\n",
+	}
+	for _, extra := range tests {
+		release := "apiVersion: helm.toolkit.fluxcd.io/v2\nkind: HelmRelease\nmetadata:\n  name: web\n  namespace: apps\nspec:\n  chart:\n    spec:\n      chart: charts/web\n      sourceRef:\n        kind: GitRepository\n        name: flux-system\n" + extra
+		repo := writeFluxRepo(t, release)
+		writeFiles(t, repo, map[string]string{"apps/web/broken.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: broken\n  namespace: apps\ndata:\n  values.yaml: \"!!notbase64\"\n"})
+		if _, err := RenderProject(filepath.Join(repo, "apps", "web"), thuleconfig.Config{Render: thuleconfig.Render{Mode: "flux", Path: "."}}); err == nil {
+			t.Fatalf("expected values error for %q", extra)
+		}
+	}
+
+	// Sources missing from the repository may exist in the cluster: the
+	// chart renders without them and the gap is recorded for the plan.
+	release := "apiVersion: helm.toolkit.fluxcd.io/v2\nkind: HelmRelease\nmetadata:\n  name: web\n  namespace: apps\nspec:\n  chart:\n    spec:\n      chart: charts/web\n      sourceRef:\n        kind: GitRepository\n        name: flux-system\n" +
+		"  valuesFrom:\n    - kind: ConfigMap\n      name: missing\n    - kind: Secret\n      name: maybe\n      optional: true\n"
+	repo := writeFluxRepo(t, release)
+	out, err := RenderProject(filepath.Join(repo, "apps", "web"), thuleconfig.Config{Render: thuleconfig.Render{Mode: "flux", Path: "."}})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	expanded := 0
+	for _, r := range out {
+		if r.Parent == "" {
+			continue
+		}
+		expanded++
+		if len(r.Unresolved) != 1 || r.Unresolved[0] != "valuesFrom ConfigMap/missing" {
+			t.Fatalf("expected the missing source to be recorded, got %+v", r.Unresolved)
+		}
+	}
+	if expanded == 0 {
+		t.Fatalf("expected expanded resources, got %+v", out)
+	}
+}
+
+func TestResourceDataValueSecretStringData(t *testing.T) {
+	r := Resource{Kind: "Secret", Body: map[string]any{"stringData": map[string]any{"k": "v"}}}
+	if v, ok, err := resourceDataValue(r, "k"); err != nil || !ok || v != "v" {
+		t.Fatalf("unexpected stringData lookup: %q %v %v", v, ok, err)
+	}
+	if _, ok, err := resourceDataValue(r, "missing"); err != nil || ok {
+		t.Fatalf("expected missing key, got %v %v", ok, err)
+	}
+}
+
+func TestFindRepoRootFallsBackToProjectRoot(t *testing.T) {
+	dir := t.TempDir()
+	if got := findRepoRoot(dir); got != dir {
+		t.Fatalf("expected fallback to project root, got %s", got)
+	}
+}
+
+const testFluxKustomization = `apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  path: ./apps/base
+  targetNamespace: prod
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+  images:
+    - name: nginx
+      newTag: "2.0"
+  patches:
+    - target:
+        kind: Deployment
+      patch: |
+        - op: replace
+          path: /spec/replicas
+          value: 5
+  postBuild:
+    substitute:
+      cluster: prod-eu
+    substituteFrom:
+      - kind: ConfigMap
+        name: cluster-vars
+      - kind: Secret
+        name: cluster-only
+`
+
+func TestRenderProjectFluxExpandsKustomization(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"clusters/prod/apps.yaml": testFluxKustomization,
+		"clusters/prod/vars.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cluster-vars\n  namespace: flux-system\ndata:\n  cluster: ignored\n  tier: gold\n",
+		"apps/base/deploy.yaml":   "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  annotations:\n    cluster: ${cluster}\n    tier: ${tier}\n    region: ${region:=eu-west1}\nspec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n        - name: web\n          image: nginx:1.25\n",
+		"apps/base/raw.yaml":      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: raw\n  annotations:\n    kustomize.toolkit.fluxcd.io/substitute: disabled\ndata:\n  script: echo ${HOME}\n",
+		"apps/base/notes.txt":     "not a manifest\n",
+	})
+	cfg := thuleconfig.Config{Namespace: "flux-system", Render: thuleconfig.Render{Mode: "flux", Path: "clusters/prod"}}
+	out, err := RenderProjectWithOptions(repo, cfg, Options{RepoRoot: repo})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	byName := map[string]Resource{}
+	for _, r := range out {
+		byName[r.Kind+"/"+r.Name] = r
+	}
+	ks, ok := byName["Kustomization/apps"]
+	if !ok {
+		t.Fatalf("expected parent Kustomization, got %+v", out)
+	}
+	deploy, ok := byName["Deployment/web"]
+	if !ok {
+		t.Fatalf("expected expanded Deployment, got %+v", out)
+	}
+	if deploy.Namespace != "prod" || deploy.Parent != ks.ID() {
+		t.Fatalf("expected targetNamespace and parent, got %+v", deploy)
+	}
+	if len(deploy.Unresolved) != 1 || deploy.Unresolved[0] != "substituteFrom Secret/cluster-only" {
+		t.Fatalf("expected the cluster-only Secret to be unresolved, got %v", deploy.Unresolved)
+	}
+	spec, _ := deploy.Body["spec"].(map[string]any)
+	if spec["replicas"] != 5 {
+		t.Fatalf("expected spec.patches applied, got %+v", spec)
+	}
+	containers, _ := spec["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)
+	if image := containers[0].(map[string]any)["image"]; image != "nginx:2.0" {
+		t.Fatalf("expected spec.images applied, got %v", image)
+	}
+	meta, _ := deploy.Body["metadata"].(map[string]any)
+	anns, _ := meta["annotations"].(map[string]any)
+	if anns["cluster"] != "prod-eu" || anns["tier"] != "gold" || anns["region"] != "eu-west1" {
+		t.Fatalf("expected postBuild substitution, got %+v", anns)
+	}
+	labels, _ := meta["labels"].(map[string]any)
+	if labels[KustomizationNameLabel] != "apps" || labels[KustomizationNamespaceLabel] != "flux-system" {
+		t.Fatalf("expected kustomize-controller labels, got %+v", labels)
+	}
+	if want := filepath.Join(repo, "apps", "base", "deploy.yaml"); deploy.SourcePath != want {
+		t.Fatalf("expected source %s, got %s", want, deploy.SourcePath)
+	}
+	inputs := map[string]bool{}
+	for _, p := range deploy.InputPaths {
+		inputs[p] = true
+	}
+	if !inputs[filepath.Join(repo, "clusters", "prod", "apps.yaml")] || !inputs[filepath.Join(repo, "clusters", "prod", "vars.yaml")] {
+		t.Fatalf("expected Kustomization and substituteFrom inputs, got %+v", deploy.InputPaths)
+	}
+
+	raw := byName["ConfigMap/raw"]
+	data, _ := raw.Body["data"].(map[string]any)
+	if data["script"] != "echo ${HOME}" {
+		t.Fatalf("expected substitution disabled, got %+v", data)
+	}
+}
+
+func TestRenderProjectFluxKustomizationErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid var": "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: apps\nspec:\n  path: ./apps\n  sourceRef:\n    kind: GitRepository\n    name: flux-system\n  postBuild:\n    substitute:\n      bad-name: x\n",
+		"bad build":   "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: apps\nspec:\n  path: ./broken\n  sourceRef:\n    kind: GitRepository\n    name: flux-system\n",
+		"bad subst":   "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: apps\nspec:\n  path: ./apps\n  sourceRef:\n    kind: GitRepository\n    name: flux-system\n  postBuild:\n    substitute:\n      name: \"\"\n",
+	}
+	for name, ks := range tests {
+		repo := t.TempDir()
+		writeFiles(t, repo, map[string]string{
+			"clusters/ks.yaml":          ks,
+			"apps/cm.yaml":              "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ${name}\n",
+			"broken/kustomization.yaml": "resources:\n  - missing.yaml\n",
+		})
+		cfg := thuleconfig.Config{Render: thuleconfig.Render{Mode: "flux", Path: "clusters"}}
+		if _, err := RenderProjectWithOptions(repo, cfg, Options{RepoRoot: repo}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRenderProjectFluxSkipsExternalKustomizationSources(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"ks.yaml": "apiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: a\nspec:\n  path: ./elsewhere\n  sourceRef:\n    kind: GitRepository\n    name: other\n---\napiVersion: kustomize.toolkit.fluxcd.io/v1\nkind: Kustomization\nmetadata:\n  name: b\nspec:\n  path: ./\n  sourceRef:\n    kind: OCIRepository\n    name: oci\n",
+	})
+	out, err := RenderProjectWithOptions(repo, thuleconfig.Config{Render: thuleconfig.Render{Mode: "flux", Path: "ks.yaml"}}, Options{RepoRoot: repo})
+	if err != nil || len(out) != 2 {
+		t.Fatalf("expected only the Kustomizations, got %v %+v", err, out)
+	}
+}
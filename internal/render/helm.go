@@ -0,0 +1,166 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/example/thule/pkg/thuleconfig"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+var helmSourcePattern = regexp.MustCompile(`(?m)^# Source: (\S+)`)
+
+// renderHelmPath templates the chart at chartPath in-process. Paths that are
+// not chart directories are treated as pre-rendered YAML.
+func renderHelmPath(projectRoot, chartPath string, cfg thuleconfig.Config, kubeVersion string) ([]Resource, error) {
+	if _, err := os.Stat(filepath.Join(chartPath, chartutil.ChartfileName)); err != nil {
+		return renderYAMLPath(chartPath)
+	}
+
+	valuesFiles := make([]string, 0, len(cfg.Render.Helm.ValuesFiles))
+	for _, f := range cfg.Render.Helm.ValuesFiles {
+		valuesFiles = append(valuesFiles, filepath.Join(projectRoot, f))
+	}
+	releaseName := cfg.Render.Helm.ReleaseName
+	if releaseName == "" {
+		releaseName = cfg.Project
+	}
+	return templateChart(chartPath, helmTemplateOptions{
+		ReleaseName: releaseName,
+		Namespace:   cfg.Namespace,
+		ValuesFiles: valuesFiles,
+		KubeVersion: kubeVersion,
+		APIVersions: cfg.Render.Helm.APIVersions,
+	})
+}
+
+type helmTemplateOptions struct {
+	ReleaseName string
+	Namespace   string
+	ValuesFiles []string
+	Values      map[string]any
+	KubeVersion string
+	APIVersions []string
+}
+
+// templateChart runs the equivalent of `helm template --include-crds`,
+// layering ValuesFiles in order and then Values on top.
+func templateChart(chartPath string, opts helmTemplateOptions) ([]Resource, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("load chart %s: %w", chartPath, err)
+	}
+	if err := action.CheckDependencies(chrt, chrt.Metadata.Dependencies); err != nil {
+		return nil, fmt.Errorf("chart %s dependencies: %w", chartPath, err)
+	}
+
+	valueOpts := values.Options{ValueFiles: opts.ValuesFiles}
+	vals, err := valueOpts.MergeValues(getter.Providers{})
+	if err != nil {
+		return nil, fmt.Errorf("helm values: %w", err)
+	}
+	vals = mergeValues(vals, opts.Values)
+
+	install := action.NewInstall(&action.Configuration{Log: func(string, ...any) {}})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.ReleaseName = opts.ReleaseName
+	install.Namespace = opts.Namespace
+	if opts.KubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(opts.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("helm kubeVersion %q: %w", opts.KubeVersion, err)
+		}
+		install.KubeVersion = kv
+	}
+	install.APIVersions = chartutil.VersionSet(opts.APIVersions)
+
+	rel, err := install.Run(chrt, vals)
+	if err != nil {
+		return nil, fmt.Errorf("helm template %s: %w", chartPath, err)
+	}
+
+	inputs := chartInputPaths(chartPath, chrt, opts.ValuesFiles)
+	manifests := releaseutil.SplitManifests(rel.Manifest)
+	keys := make([]string, 0, len(manifests))
+	for k := range manifests {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	out := []Resource{}
+	for _, k := range keys {
+		content := manifests[k]
+		sourcePath := ""
+		if m := helmSourcePattern.FindStringSubmatch(content); m != nil {
+			sourcePath = chartFilePath(chartPath, m[1])
+		}
+		res, err := parseYAMLDocumentsWithSource(content, sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("parse helm output %s: %w", sourcePath, err)
+		}
+		for i := range res {
+			res[i].InputPaths = inputs
+		}
+		out = append(out, res...)
+	}
+	return out, nil
+}
+
+// chartFilePath maps a "# Source: <chart>/templates/x.yaml" header back to the
+// file on disk, including files of vendored subcharts.
+func chartFilePath(chartPath, source string) string {
+	_, rel, ok := strings.Cut(source, "/")
+	if !ok {
+		return ""
+	}
+	return filepath.Join(chartPath, filepath.FromSlash(rel))
+}
+
+// chartInputPaths lists files whose changes affect every rendered resource:
+// values files, Chart.yaml, the chart's default values and template helpers.
+func chartInputPaths(chartPath string, chrt *chart.Chart, valuesFiles []string) []string {
+	inputs := append([]string{}, valuesFiles...)
+	inputs = append(inputs, filepath.Join(chartPath, chartutil.ChartfileName), filepath.Join(chartPath, chartutil.ValuesfileName))
+	var walk func(prefix string, c *chart.Chart)
+	walk = func(prefix string, c *chart.Chart) {
+		for _, t := range c.Templates {
+			if strings.HasSuffix(t.Name, ".tpl") {
+				inputs = append(inputs, filepath.Join(prefix, filepath.FromSlash(t.Name)))
+			}
+		}
+		for _, dep := range c.Dependencies() {
+			walk(filepath.Join(prefix, "charts", dep.Name()), dep)
+		}
+	}
+	walk(chartPath, chrt)
+	return inputs
+}
+
+func mergeValues(base, overlay map[string]any) map[string]any {
+	if base == nil {
+		base = map[string]any{}
+	}
+	for k, v := range overlay {
+		if vm, ok := v.(map[string]any); ok {
+			if bm, ok := base[k].(map[string]any); ok {
+				base[k] = mergeValues(bm, vm)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
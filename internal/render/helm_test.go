@@ -0,0 +1,125 @@
+package render
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+func writeTestChart(t *testing.T, root string) {
+	t.Helper()
+	writeFiles(t, root, map[string]string{
+		"chart/Chart.yaml":             "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"chart/values.yaml":            "replicas: 1\nimage: nginx:1.25\n",
+		"chart/templates/_helpers.tpl": "{{- define \"web.name\" -}}{{ .Release.Name }}-web{{- end -}}\n",
+		"chart/templates/deploy.yaml":  "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: {{ include \"web.name\" . }}\n  namespace: {{ .Release.Namespace }}\n  annotations:\n    kube: {{ .Capabilities.KubeVersion.Version | quote }}\nspec:\n  replicas: {{ .Values.replicas }}\n  template:\n    spec:\n      containers:\n        - name: web\n          image: {{ .Values.image }}\n",
+		"chart/crds/widget.yaml":       "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\n",
+		"values/prod.yaml":             "replicas: 3\n",
+	})
+}
+
+func TestRenderProjectHelmTemplatesChart(t *testing.T) {
+	dir := t.TempDir()
+	writeTestChart(t, dir)
+
+	cfg := thuleconfig.Config{
+		Project:     "payments",
+		Namespace:   "pay",
+		KubeVersion: "v1.29.4",
+		Render: thuleconfig.Render{Mode: "helm", Path: "chart", Helm: thuleconfig.Helm{
+			ReleaseName: "checkout",
+			ValuesFiles: []string{"values/prod.yaml"},
+		}},
+	}
+	out, err := RenderProject(dir, cfg)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	byKind := map[string]Resource{}
+	for _, r := range out {
+		byKind[r.Kind] = r
+	}
+	if _, ok := byKind["CustomResourceDefinition"]; !ok {
+		t.Fatalf("expected chart CRDs to be included, got %+v", out)
+	}
+	deploy, ok := byKind["Deployment"]
+	if !ok {
+		t.Fatalf("expected deployment, got %+v", out)
+	}
+	if deploy.Name != "checkout-web" || deploy.Namespace != "pay" {
+		t.Fatalf("unexpected release naming: %+v", deploy)
+	}
+	spec, _ := deploy.Body["spec"].(map[string]any)
+	if spec["replicas"] != 3 {
+		t.Fatalf("expected values file override, got %+v", spec)
+	}
+	meta, _ := deploy.Body["metadata"].(map[string]any)
+	anns, _ := meta["annotations"].(map[string]any)
+	if anns["kube"] != "v1.29.4" {
+		t.Fatalf("expected configured kube version capability, got %+v", anns)
+	}
+	if want := filepath.Join(dir, "chart", "templates", "deploy.yaml"); deploy.SourcePath != want {
+		t.Fatalf("expected source %s, got %s", want, deploy.SourcePath)
+	}
+	inputs := map[string]bool{}
+	for _, p := range deploy.InputPaths {
+		inputs[p] = true
+	}
+	for _, want := range []string{
+		filepath.Join(dir, "values", "prod.yaml"),
+		filepath.Join(dir, "chart", "values.yaml"),
+		filepath.Join(dir, "chart", "templates", "_helpers.tpl"),
+	} {
+		if !inputs[want] {
+			t.Fatalf("expected input path %s, got %+v", want, deploy.InputPaths)
+		}
+	}
+}
+
+func TestRenderProjectHelmDefaultsReleaseNameToProject(t *testing.T) {
+	dir := t.TempDir()
+	writeTestChart(t, dir)
+	cfg := thuleconfig.Config{Project: "payments", Namespace: "pay", Render: thuleconfig.Render{Mode: "helm", Path: "chart"}}
+	out, err := RenderProject(dir, cfg)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, r := range out {
+		if r.Kind == "Deployment" && r.Name != "payments-web" {
+			t.Fatalf("expected project as release name, got %s", r.Name)
+		}
+	}
+}
+
+func TestRenderProjectHelmErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestChart(t, dir)
+	tests := []thuleconfig.Config{
+		{Project: "p", Namespace: "n", KubeVersion: "not-a-version", Render: thuleconfig.Render{Mode: "helm", Path: "chart"}},
+		{Project: "p", Namespace: "n", Render: thuleconfig.Render{Mode: "helm", Path: "chart", Helm: thuleconfig.Helm{ValuesFiles: []string{"missing.yaml"}}}},
+	}
+	for _, cfg := range tests {
+		if _, err := RenderProject(dir, cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+
+	writeFiles(t, dir, map[string]string{"broken/Chart.yaml": "apiVersion: v2\nname: broken\nversion: 0.1.0\n", "broken/templates/x.yaml": "{{ .Values.missing.field }}\n"})
+	if _, err := RenderProject(dir, thuleconfig.Config{Project: "p", Namespace: "n", Render: thuleconfig.Render{Mode: "helm", Path: "broken"}}); err == nil {
+		t.Fatal("expected template error")
+	}
+	writeFiles(t, dir, map[string]string{"bad/Chart.yaml": "name: [\n"})
+	if _, err := RenderProject(dir, thuleconfig.Config{Project: "p", Namespace: "n", Render: thuleconfig.Render{Mode: "helm", Path: "bad"}}); err == nil {
+		t.Fatal("expected chart load error")
+	}
+}
+
+func TestChartFilePath(t *testing.T) {
+	if got := chartFilePath("/c", "web/charts/db/templates/sts.yaml"); got != filepath.Join("/c", "charts", "db", "templates", "sts.yaml") {
+		t.Fatalf("unexpected subchart path: %s", got)
+	}
+	if got := chartFilePath("/c", "noslash"); got != "" {
+		t.Fatalf("expected empty path, got %s", got)
+	}
+}
@@ -0,0 +1,242 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// renderKustomizePath runs an in-process kustomize build on path, matching the
+// options kustomize-controller uses (no load restrictions, plugins disabled).
+// Directories without a kustomization file fall back to plain YAML rendering.
+func renderKustomizePath(path string) ([]Resource, error) {
+	kustomizationFile, err := findKustomizationFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if kustomizationFile == "" {
+		return renderYAMLPath(path)
+	}
+	return buildKustomization(path, kustomizationFile, nil)
+}
+
+func findKustomizationFile(dir string) (string, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", nil
+	}
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// buildKustomization runs kustomize build on path. When kustomizationFile
+// does not exist on disk, a kustomization listing every manifest under path is
+// generated, as kustomize-controller does. overlay, when set, edits the root
+// kustomization before the build. Resources record the kustomization, patch
+// and generator files the build read as InputPaths.
+func buildKustomization(path, kustomizationFile string, overlay func(doc map[string]any)) ([]Resource, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	absKustomization, err := filepath.Abs(kustomizationFile)
+	if err != nil {
+		return nil, err
+	}
+	tracking := &rootKustomizationFS{FileSystem: filesys.MakeFsOnDisk(), rootKustomization: absKustomization, overlay: overlay}
+	if _, err := os.Stat(absKustomization); os.IsNotExist(err) {
+		resources, err := generatedKustomizationResources(absPath)
+		if err != nil {
+			return nil, err
+		}
+		tracking.generated, err = yaml.Marshal(map[string]any{
+			"apiVersion": "kustomize.config.k8s.io/v1beta1",
+			"kind":       "Kustomization",
+			"resources":  resources,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := krusty.MakeDefaultOptions()
+	opts.LoadRestrictions = types.LoadRestrictionsNone
+	opts.PluginConfig = types.DisabledPluginConfig()
+	resMap, err := krusty.MakeKustomizer(opts).Run(tracking, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build %s: %w", path, err)
+	}
+
+	out := make([]Resource, 0, resMap.Size())
+	manifests := map[string]struct{}{}
+	for _, res := range resMap.Resources() {
+		origin, err := res.GetOrigin()
+		if err != nil {
+			return nil, fmt.Errorf("kustomize origin for %s: %w", res.CurId(), err)
+		}
+		if !tracking.userOriginAnnotations {
+			if err := res.SetOrigin(nil); err != nil {
+				return nil, err
+			}
+		}
+		doc, err := res.Map()
+		if err != nil {
+			return nil, fmt.Errorf("kustomize output %s: %w", res.CurId(), err)
+		}
+		sourcePath := ""
+		if origin != nil && origin.Repo == "" {
+			// Generated resources carry the kustomization that configured them.
+			rel := origin.Path
+			if rel != "" {
+				manifests[filepath.Join(path, rel)] = struct{}{}
+			} else {
+				rel = origin.ConfiguredIn
+			}
+			if rel != "" {
+				sourcePath = filepath.Join(path, rel)
+			}
+		}
+		if r, ok := resourceFromDocument(doc, sourcePath); ok {
+			out = append(out, r)
+		}
+	}
+	// Kustomization, patch and generator files shape every resource of the
+	// build; manifests only the resources they declare.
+	inputs := []string{}
+	for _, read := range tracking.readFiles() {
+		rel, err := filepath.Rel(absPath, read)
+		if err != nil {
+			continue
+		}
+		input := filepath.Join(path, rel)
+		if _, ok := manifests[input]; !ok {
+			inputs = append(inputs, input)
+		}
+	}
+	for i := range out {
+		out[i].InputPaths = inputs
+	}
+	return out, nil
+}
+
+// rootKustomizationFS enables kustomize origin annotations on the root
+// kustomization so rendered resources can be attributed to the file they came
+// from. Nested kustomizations inherit buildMetadata from the root.
+type rootKustomizationFS struct {
+	filesys.FileSystem
+	rootKustomization     string
+	generated             []byte
+	overlay               func(doc map[string]any)
+	userOriginAnnotations bool
+	read                  map[string]struct{}
+}
+
+func (f *rootKustomizationFS) ReadFile(path string) ([]byte, error) {
+	if filepath.Clean(path) != f.rootKustomization {
+		content, err := f.FileSystem.ReadFile(path)
+		if err == nil {
+			f.markRead(path)
+		}
+		return content, err
+	}
+	content := f.generated
+	if content == nil {
+		var err error
+		if content, err = f.FileSystem.ReadFile(path); err != nil {
+			return content, err
+		}
+		f.markRead(path)
+	}
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		// Let kustomize report the parse error against the original content.
+		return content, nil
+	}
+	metadata, _ := doc["buildMetadata"].([]any)
+	for _, m := range metadata {
+		if m == types.OriginAnnotations {
+			f.userOriginAnnotations = true
+		}
+	}
+	if !f.userOriginAnnotations {
+		doc["buildMetadata"] = append(metadata, types.OriginAnnotations)
+	}
+	if f.overlay != nil {
+		f.overlay(doc)
+	}
+	return yaml.Marshal(doc)
+}
+
+func (f *rootKustomizationFS) markRead(path string) {
+	if f.read == nil {
+		f.read = map[string]struct{}{}
+	}
+	f.read[filepath.Clean(path)] = struct{}{}
+}
+
+// readFiles lists the files the build read, sorted.
+func (f *rootKustomizationFS) readFiles() []string {
+	out := make([]string, 0, len(f.read))
+	for p := range f.read {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// generatedKustomizationResources lists manifest files under dir, and
+// subdirectories that carry their own kustomization, relative to dir.
+func generatedKustomizationResources(dir string) ([]any, error) {
+	resources := []any{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == dir {
+				return nil
+			}
+			if kf, err := findKustomizationFile(p); err != nil {
+				return err
+			} else if kf != "" {
+				resources = append(resources, filepath.ToSlash(rel))
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if looksLikeKubernetesManifest(string(content)) {
+			resources = append(resources, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return resources, err
+}
@@ -0,0 +1,126 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/example/thule/pkg/thuleconfig"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRenderProjectKustomizeAppliesOverlay(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base/kustomization.yaml":          "resources:\n  - deploy.yaml\n",
+		"base/deploy.yaml":                 "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n        - name: web\n          image: nginx:1.25\n",
+		"overlays/prod/kustomization.yaml": "namespace: prod\nnamePrefix: prod-\ncommonLabels:\n  team: payments\nresources:\n  - ../../base\npatches:\n  - path: replicas.yaml\nconfigMapGenerator:\n  - name: settings\n    literals:\n      - LOG_LEVEL=info\n",
+		"overlays/prod/replicas.yaml":      "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n",
+	})
+
+	cfg := thuleconfig.Config{Render: thuleconfig.Render{Mode: "kustomize", Path: "overlays/prod"}}
+	out, err := RenderProject(dir, cfg)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected deployment and generated configmap, got %+v", out)
+	}
+	byKind := map[string]Resource{}
+	for _, r := range out {
+		byKind[r.Kind] = r
+	}
+
+	deploy := byKind["Deployment"]
+	if deploy.Name != "prod-web" || deploy.Namespace != "prod" {
+		t.Fatalf("expected prefixed/namespaced deployment, got %+v", deploy)
+	}
+	spec, _ := deploy.Body["spec"].(map[string]any)
+	if spec["replicas"] != 3 {
+		t.Fatalf("expected patched replicas, got %+v", spec)
+	}
+	meta, _ := deploy.Body["metadata"].(map[string]any)
+	labels, _ := meta["labels"].(map[string]any)
+	if labels["team"] != "payments" {
+		t.Fatalf("expected common label, got %+v", meta)
+	}
+	if _, ok := meta["annotations"]; ok {
+		t.Fatalf("expected origin annotations to be stripped, got %+v", meta)
+	}
+	if want := filepath.Join(dir, "base", "deploy.yaml"); deploy.SourcePath != want {
+		t.Fatalf("expected source %s, got %s", want, deploy.SourcePath)
+	}
+	wantInputs := []string{
+		filepath.Join(dir, "base", "kustomization.yaml"),
+		filepath.Join(dir, "overlays", "prod", "kustomization.yaml"),
+		filepath.Join(dir, "overlays", "prod", "replicas.yaml"),
+	}
+	if strings.Join(deploy.InputPaths, ",") != strings.Join(wantInputs, ",") {
+		t.Fatalf("expected kustomization and patch files as inputs, got %v", deploy.InputPaths)
+	}
+
+	cm := byKind["ConfigMap"]
+	if !strings.HasPrefix(cm.Name, "prod-settings-") {
+		t.Fatalf("expected hashed generator name, got %s", cm.Name)
+	}
+	if want := filepath.Join(dir, "overlays", "prod", "kustomization.yaml"); cm.SourcePath != want {
+		t.Fatalf("expected generated configmap attributed to %s, got %s", want, cm.SourcePath)
+	}
+}
+
+func TestRenderProjectKustomizeKeepsRequestedOriginAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"kustomization.yaml": "buildMetadata:\n  - originAnnotations\nresources:\n  - cm.yaml\n",
+		"cm.yaml":            "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+	})
+	out, err := RenderProject(dir, thuleconfig.Config{Render: thuleconfig.Render{Mode: "kustomize", Path: "."}})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("unexpected resources: %+v", out)
+	}
+	meta, _ := out[0].Body["metadata"].(map[string]any)
+	anns, _ := meta["annotations"].(map[string]any)
+	if _, ok := anns["config.kubernetes.io/origin"]; !ok {
+		t.Fatalf("expected user-requested origin annotation, got %+v", meta)
+	}
+}
+
+func TestRenderProjectKustomizeWithoutKustomizationFallsBackToYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"manifests/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+	})
+	out, err := RenderProject(dir, thuleconfig.Config{Render: thuleconfig.Render{Mode: "kustomize", Path: "manifests"}})
+	if err != nil || len(out) != 1 {
+		t.Fatalf("unexpected render result: %v %+v", err, out)
+	}
+}
+
+func TestRenderProjectKustomizeBuildError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"kustomization.yaml": "resources:\n  - missing.yaml\n",
+	})
+	if _, err := RenderProject(dir, thuleconfig.Config{Render: thuleconfig.Render{Mode: "kustomize", Path: "."}}); err == nil {
+		t.Fatal("expected kustomize build error")
+	}
+	if _, err := RenderProject(dir, thuleconfig.Config{Render: thuleconfig.Render{Mode: "kustomize", Path: "missing"}}); err == nil {
+		t.Fatal("expected missing path error")
+	}
+}
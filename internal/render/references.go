@@ -0,0 +1,106 @@
+package render
+
+import "fmt"
+
+// Reference is a namespaced core (v1) object another resource depends on by
+// name.
+type Reference struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Via describes where the reference is made, e.g. "env" or "volume data".
+	Via string
+	// Optional references (optional: true) do not block pod startup.
+	Optional bool
+}
+
+// ID matches the ID of the referenced object's Resource.
+func (r Reference) ID() string {
+	return Resource{APIVersion: "v1", Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}.ID()
+}
+
+// podSpecPaths locates the pod spec of workload kinds.
+var podSpecPaths = map[string][]string{
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+	"Pod":         {"spec"},
+}
+
+// PodSpec returns the pod spec of a workload, if r is one.
+func (r Resource) PodSpec() (map[string]any, bool) {
+	path, ok := podSpecPaths[r.Kind]
+	if !ok {
+		return nil, false
+	}
+	var cur any = r.Body
+	for _, p := range path {
+		m, _ := cur.(map[string]any)
+		cur = m[p]
+	}
+	spec, ok := cur.(map[string]any)
+	return spec, ok
+}
+
+// References lists the ConfigMaps, Secrets, PersistentVolumeClaims and
+// ServiceAccount the pod spec of a workload consumes through env, envFrom,
+// volumes, imagePullSecrets and serviceAccountName.
+func (r Resource) References() []Reference {
+	spec, ok := r.PodSpec()
+	if !ok {
+		return nil
+	}
+	out := []Reference{}
+	add := func(kind string, source map[string]any, nameKey, via string) {
+		if n, ok := source[nameKey].(string); ok && n != "" {
+			optional, _ := source["optional"].(bool)
+			out = append(out, Reference{Kind: kind, Namespace: r.Namespace, Name: n, Via: via, Optional: optional})
+		}
+	}
+	child := func(m map[string]any, key string) map[string]any {
+		c, _ := m[key].(map[string]any)
+		return c
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		for _, c := range objects(spec[field]) {
+			for _, env := range objects(c["env"]) {
+				from := child(env, "valueFrom")
+				add("ConfigMap", child(from, "configMapKeyRef"), "name", "env")
+				add("Secret", child(from, "secretKeyRef"), "name", "env")
+			}
+			for _, envFrom := range objects(c["envFrom"]) {
+				add("ConfigMap", child(envFrom, "configMapRef"), "name", "envFrom")
+				add("Secret", child(envFrom, "secretRef"), "name", "envFrom")
+			}
+		}
+	}
+	for _, v := range objects(spec["volumes"]) {
+		via := fmt.Sprintf("volume %v", v["name"])
+		add("ConfigMap", child(v, "configMap"), "name", via)
+		add("Secret", child(v, "secret"), "secretName", via)
+		add("PersistentVolumeClaim", child(v, "persistentVolumeClaim"), "claimName", via)
+		for _, src := range objects(child(v, "projected")["sources"]) {
+			add("ConfigMap", child(src, "configMap"), "name", via)
+			add("Secret", child(src, "secret"), "name", via)
+		}
+	}
+	for _, s := range objects(spec["imagePullSecrets"]) {
+		add("Secret", s, "name", "imagePullSecrets")
+	}
+	add("ServiceAccount", spec, "serviceAccountName", "serviceAccountName")
+	return out
+}
+
+func objects(v any) []map[string]any {
+	items, _ := v.([]any)
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -25,6 +25,16 @@ type Resource struct {
 	Name       string
 	Body       map[string]any
 	SourcePath string
+	// InputPaths lists other files (values files, chart helpers) whose
+	// changes affect the rendered resource.
+	InputPaths []string
+	// Parent is the ID of the Flux HelmRelease or Kustomization the resource
+	// was expanded from, if any.
+	Parent string
+	// Unresolved lists the non-optional valuesFrom/substituteFrom sources of
+	// Parent that are not defined in the repository, e.g. "valuesFrom
+	// Secret/web-values"; the resource was rendered without them.
+	Unresolved []string
 }
 
 func (r Resource) ID() string {
@@ -35,17 +45,53 @@ func (r Resource) ID() string {
 	return fmt.Sprintf("%s|%s|%s|%s", r.APIVersion, r.Kind, ns, r.Name)
 }
 
+// Options carries repository context that is not part of thule.conf.
+type Options struct {
+	// RepoRoot is the repository checkout containing the project. Flux
+	// GitRepository chart paths resolve against it. When empty, the nearest
+	// parent of the project containing .git is used, else the project root.
+	RepoRoot string
+	// KubeVersion is the Kubernetes version of the project's cluster, used
+	// for Helm .Capabilities. When empty, cfg.KubeVersion is used.
+	KubeVersion string
+}
+
 func RenderProject(projectRoot string, cfg thuleconfig.Config) ([]Resource, error) {
+	return RenderProjectWithOptions(projectRoot, cfg, Options{})
+}
+
+func RenderProjectWithOptions(projectRoot string, cfg thuleconfig.Config, opts Options) ([]Resource, error) {
 	target := filepath.Join(projectRoot, cfg.Render.Path)
+	kubeVersion := opts.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = cfg.KubeVersion
+	}
 	switch cfg.Render.Mode {
-	case "yaml", "kustomize", "helm":
+	case "yaml":
 		return renderYAMLPath(target)
+	case "helm":
+		return renderHelmPath(projectRoot, target, cfg, kubeVersion)
+	case "kustomize":
+		return renderKustomizePath(target)
 	case "flux":
 		resources, err := renderYAMLPath(target)
 		if err != nil {
 			return nil, err
 		}
-		return filterFluxResources(resources, cfg), nil
+		repoRoot := opts.RepoRoot
+		if repoRoot == "" {
+			repoRoot = findRepoRoot(projectRoot)
+		}
+		releases, err := expandHelmReleases(resources, repoRoot, cfg.Namespace, kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		kustomizations, err := expandKustomizations(resources, repoRoot, cfg.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		out := append(filterFluxResources(resources, cfg), releases...)
+		return append(out, kustomizations...), nil
 	default:
 		return nil, fmt.Errorf("render mode %q not implemented", cfg.Render.Mode)
 	}
@@ -138,27 +184,35 @@ func parseYAMLDocumentsWithSource(content, sourcePath string) ([]Resource, error
 		if len(doc) == 0 {
 			continue
 		}
-		apiVersion, _ := doc["apiVersion"].(string)
-		kind, _ := doc["kind"].(string)
-		meta, _ := doc["metadata"].(map[string]any)
-		name, _ := meta["name"].(string)
-		namespace, _ := meta["namespace"].(string)
-		if apiVersion == "" || kind == "" || name == "" {
+		r, ok := resourceFromDocument(doc, sourcePath)
+		if !ok {
 			// Skip non-resource YAML (values files, kustomize configs, etc.).
 			continue
 		}
-		out = append(out, Resource{
-			APIVersion: apiVersion,
-			Kind:       kind,
-			Namespace:  namespace,
-			Name:       name,
-			Body:       doc,
-			SourcePath: sourcePath,
-		})
+		out = append(out, r)
 	}
 	return out, nil
 }
 
+func resourceFromDocument(doc map[string]any, sourcePath string) (Resource, bool) {
+	apiVersion, _ := doc["apiVersion"].(string)
+	kind, _ := doc["kind"].(string)
+	meta, _ := doc["metadata"].(map[string]any)
+	name, _ := meta["name"].(string)
+	namespace, _ := meta["namespace"].(string)
+	if apiVersion == "" || kind == "" || name == "" {
+		return Resource{}, false
+	}
+	return Resource{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+		Body:       doc,
+		SourcePath: sourcePath,
+	}, true
+}
+
 func looksLikeKubernetesManifest(content string) bool {
 	return apiVersionPattern.MatchString(content) && kindPattern.MatchString(content)
 }
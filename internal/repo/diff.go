@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func ChangedFiles(repoDir, baseRef, headSHA string) ([]string, error) {
@@ -19,25 +20,11 @@ func ChangedFiles(repoDir, baseRef, headSHA string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open repo: %w", err)
 	}
-
-	baseHash, err := resolveRef(repo, baseRef)
+	headCommit, mergeBase, err := mergeBaseCommits(repo, baseRef, headSHA)
 	if err != nil {
 		return nil, err
 	}
 
-	headCommit, err := repo.CommitObject(plumbing.NewHash(headSHA))
-	if err != nil {
-		return nil, fmt.Errorf("head commit: %w", err)
-	}
-	baseCommit, err := repo.CommitObject(baseHash)
-	if err != nil {
-		return nil, fmt.Errorf("base commit: %w", err)
-	}
-	mergeBase := baseCommit
-	if bases, err := headCommit.MergeBase(baseCommit); err == nil && len(bases) > 0 {
-		mergeBase = bases[0]
-	}
-
 	patch, err := mergeBase.Patch(headCommit)
 	if err != nil {
 		return nil, fmt.Errorf("diff commits: %w", err)
@@ -67,6 +54,29 @@ func ChangedFiles(repoDir, baseRef, headSHA string) ([]string, error) {
 	return out, nil
 }
 
+// mergeBaseCommits returns the head commit and its merge base with baseRef,
+// falling back to the baseRef commit when the histories are unrelated.
+func mergeBaseCommits(repo *git.Repository, baseRef, headSHA string) (*object.Commit, *object.Commit, error) {
+	baseHash, err := resolveRef(repo, baseRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headCommit, err := repo.CommitObject(plumbing.NewHash(headSHA))
+	if err != nil {
+		return nil, nil, fmt.Errorf("head commit: %w", err)
+	}
+	baseCommit, err := repo.CommitObject(baseHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("base commit: %w", err)
+	}
+	mergeBase := baseCommit
+	if bases, err := headCommit.MergeBase(baseCommit); err == nil && len(bases) > 0 {
+		mergeBase = bases[0]
+	}
+	return headCommit, mergeBase, nil
+}
+
 func resolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
 	if ref == "" {
 		return plumbing.Hash{}, fmt.Errorf("base ref is empty")
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// MergeBaseTrees materializes the merge base of a merge request from the
+// worker clone so the target branch state can be rendered next to the head
+// checkout.
+type MergeBaseTrees struct {
+	repoDir string
+	baseRef string
+}
+
+func NewMergeBaseTrees(repoDir, defaultBaseRef string) *MergeBaseTrees {
+	return &MergeBaseTrees{repoDir: repoDir, baseRef: defaultBaseRef}
+}
+
+// MergeBaseTree writes the merge base of baseRef (or the default base ref)
+// and headSHA into a temporary directory. The caller must invoke cleanup once
+// done with it.
+func (m *MergeBaseTrees) MergeBaseTree(_ context.Context, baseRef, headSHA string) (string, func(), error) {
+	if baseRef == "" {
+		baseRef = m.baseRef
+	}
+	dir, err := os.MkdirTemp("", "thule-base-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create base tree dir: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	if _, err := ExportMergeBase(m.repoDir, baseRef, headSHA, dir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// ExportMergeBase writes the tree of the merge base of baseRef and headSHA to
+// dir and returns the merge base commit hash.
+func ExportMergeBase(repoDir, baseRef, headSHA, dir string) (string, error) {
+	if repoDir == "" {
+		return "", fmt.Errorf("repo dir is empty")
+	}
+	if headSHA == "" {
+		return "", fmt.Errorf("head sha is empty")
+	}
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return "", fmt.Errorf("open repo: %w", err)
+	}
+	_, mergeBase, err := mergeBaseCommits(repo, baseRef, headSHA)
+	if err != nil {
+		return "", err
+	}
+	tree, err := mergeBase.Tree()
+	if err != nil {
+		return "", fmt.Errorf("merge base tree: %w", err)
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		// Symlinks and submodules are not needed to render manifests.
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		return writeTreeFile(f, filepath.Join(dir, filepath.FromSlash(f.Name)))
+	})
+	if err != nil {
+		return "", fmt.Errorf("export merge base %s: %w", mergeBase.Hash, err)
+	}
+	return mergeBase.Hash.String(), nil
+}
+
+func writeTreeFile(f *object.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
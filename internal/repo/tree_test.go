@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestMergeBaseTree(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatalf("init repo: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	commit := func(msg string, files map[string]string, removed ...string) plumbing.Hash {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(repoDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("write file: %v", err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+		for _, name := range removed {
+			if _, err := wt.Remove(name); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+		}
+		hash, err := wt.Commit(msg, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+		if err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		return hash
+	}
+	base := commit("base", map[string]string{"apps/a.yaml": "a", "apps/b.yaml": "b"})
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}); err != nil {
+		t.Fatalf("checkout feature: %v", err)
+	}
+	head := commit("feature", nil, "apps/b.yaml")
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("master")}); err != nil {
+		t.Fatalf("checkout master: %v", err)
+	}
+	commit("master moves on", map[string]string{"apps/c.yaml": "c"})
+
+	dir, cleanup, err := NewMergeBaseTrees(repoDir, "master").MergeBaseTree(context.Background(), "", head.String())
+	if err != nil {
+		t.Fatalf("merge base tree: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "apps", "b.yaml")); err != nil || string(got) != "b" {
+		t.Fatalf("expected removed file at merge base, got %q %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "apps", "c.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("expected target branch commits after the merge base to be excluded, got %v", err)
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected cleanup to remove %s", dir)
+	}
+
+	if got, err := ExportMergeBase(repoDir, base.String(), head.String(), t.TempDir()); err != nil || got != base.String() {
+		t.Fatalf("expected merge base %s, got %s %v", base, got, err)
+	}
+	if _, _, err := NewMergeBaseTrees(repoDir, "missing").MergeBaseTree(context.Background(), "", head.String()); err == nil {
+		t.Fatal("expected error for missing base ref")
+	}
+}
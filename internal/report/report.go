@@ -15,11 +15,32 @@ const (
 	maxYAMLCharsPerBlock      = 12000
 )
 
+const gitOnlyNote = "> Repository-only diff: compared against the merge base render, not the live cluster.\n\n"
+
 type ProjectPlan struct {
 	Project  string
 	Changes  []diff.Change
 	Summary  diff.Summary
 	Findings []policy.Finding
+	// GitOnly marks plans diffed against the merge base render instead of
+	// the live cluster.
+	GitOnly bool
+	// Suppressed describes fields left out of the diff because a controller
+	// owns them, e.g. replica counts of autoscaled workloads.
+	Suppressed []string
+	// Rollouts estimates which workloads restart because of the changes.
+	Rollouts []RolloutImpact
+}
+
+// RolloutImpact describes how a planned change reaches a workload's pods.
+type RolloutImpact struct {
+	Workload string
+	// Restart is set when the pods roll; otherwise the workload consumes a
+	// changed ConfigMap or Secret without restarting.
+	Restart bool
+	// Pods is the estimated number of pods affected, -1 when unknown.
+	Pods    int
+	Reasons []string
 }
 
 func BuildPlanComment(project string, sha string, changes []diff.Change, summary diff.Summary, findings []policy.Finding, maxResourceDetails int) string {
@@ -65,6 +86,7 @@ func BuildAggregatedPlanComment(sha string, projects []ProjectPlan, maxResourceD
 		total.Patches += p.Summary.Patches
 		total.Deletes += p.Summary.Deletes
 		total.NoOps += p.Summary.NoOps
+		total.Renames += p.Summary.Renames
 	}
 
 	var b strings.Builder
@@ -83,12 +105,24 @@ func BuildAggregatedPlanComment(sha string, projects []ProjectPlan, maxResourceD
 			break
 		}
 		b.WriteString(header)
+		if p.GitOnly {
+			b.WriteString(gitOnlyNote)
+		}
+		if len(p.Suppressed) > 0 {
+			b.WriteString(suppressedNote(p.Suppressed))
+		}
 		sLine := summaryLine(p.Summary) + "\n\n"
 		if b.Len()+len(sLine) > maxCommentChars {
 			b.WriteString("- ... truncated (comment size limit)\n")
 			break
 		}
 		b.WriteString(sLine)
+		if section := rolloutSection(p.Rollouts); section != "" && b.Len()+len(section) <= maxCommentChars {
+			b.WriteString(section)
+		}
+		if table := imageTable(p.Changes); table != "" && b.Len()+len(table) <= maxCommentChars {
+			b.WriteString(table)
+		}
 		appendPlanSections(&b, p.Changes, p.Findings, maxResourceDetails, "#### Changes", "#### Policy Findings")
 	}
 
@@ -96,8 +130,77 @@ func BuildAggregatedPlanComment(sha string, projects []ProjectPlan, maxResourceD
 	return b.String()
 }
 
+// imageTable summarizes the image changes the MR introduces, one row per
+// container.
+func imageTable(changes []diff.Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		if c.Origin == diff.OriginDrift {
+			continue
+		}
+		for _, img := range c.Images {
+			if b.Len() == 0 {
+				b.WriteString("| Resource | Container | Old | New |\n|---|---|---|---|\n")
+			}
+			// Pipes in resource IDs would end the table cell.
+			b.WriteString(fmt.Sprintf("| `%s` | `%s` | %s | %s |\n", strings.ReplaceAll(c.ID, "|", "\\|"), img.Container, imageCell(img.Old), imageCell(img.New)))
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func rolloutSection(impacts []RolloutImpact) string {
+	if len(impacts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	restarts, pods := 0, 0
+	for _, r := range impacts {
+		if r.Restart {
+			restarts++
+			if r.Pods > 0 {
+				pods += r.Pods
+			}
+		}
+	}
+	b.WriteString(fmt.Sprintf("#### Rollout impact\n%d workloads restart (~%d pods)\n", restarts, pods))
+	for _, r := range impacts {
+		effect := "not restarted"
+		if r.Restart {
+			effect = "restarts"
+			if r.Pods >= 0 {
+				effect += fmt.Sprintf(" %d pods", r.Pods)
+			}
+		}
+		b.WriteString(fmt.Sprintf("- `%s` %s: %s\n", r.Workload, effect, strings.Join(r.Reasons, "; ")))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func imageCell(ref diff.ImageRef) string {
+	if ref == (diff.ImageRef{}) {
+		return "-"
+	}
+	return "`" + ref.String() + "`"
+}
+
+func suppressedNote(fields []string) string {
+	var b strings.Builder
+	b.WriteString("> Not diffed (managed by autoscalers):\n")
+	for _, f := range fields {
+		b.WriteString("> - " + f + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
 func hasActionableChanges(plan ProjectPlan) bool {
-	if plan.Summary.Creates > 0 || plan.Summary.Patches > 0 || plan.Summary.Deletes > 0 {
+	if plan.Summary.Creates > 0 || plan.Summary.Patches > 0 || plan.Summary.Deletes > 0 || plan.Summary.Renames > 0 {
 		return true
 	}
 	return len(plan.Findings) > 0
@@ -105,37 +208,108 @@ func hasActionableChanges(plan ProjectPlan) bool {
 
 func appendPlanSections(b *strings.Builder, changes []diff.Change, findings []policy.Finding, maxResourceDetails int, changesHeading, findingsHeading string) {
 	b.WriteString(changesHeading + "\n")
-	printed := 0
-	nonNoopTotal := 0
+	introduced := make([]diff.Change, 0, len(changes))
+	drift := []diff.Change{}
 	for _, c := range changes {
-		if c.Action != diff.NoOp {
-			nonNoopTotal++
+		switch {
+		case c.Action == diff.NoOp:
+		case c.Origin == diff.OriginDrift:
+			drift = append(drift, c)
+		default:
+			introduced = append(introduced, c)
 		}
 	}
-	sizeTruncated := false
-	for _, c := range changes {
-		if c.Action == diff.NoOp {
-			continue
+	if appendChangeList(b, introduced, maxResourceDetails) == 0 {
+		b.WriteString("- none\n")
+	}
+	if len(drift) > 0 {
+		b.WriteString(fmt.Sprintf("\n<details><summary>Pre-existing drift (%d resources, not introduced by this MR)</summary>\n\n", len(drift)))
+		appendChangeList(b, drift, maxResourceDetails)
+		b.WriteString("\n</details>\n")
+	}
+
+	b.WriteString("\n" + findingsHeading + "\n")
+	active := make([]policy.Finding, 0, len(findings))
+	waived := []policy.Finding{}
+	for _, f := range findings {
+		if f.Waiver != nil {
+			waived = append(waived, f)
+		} else {
+			active = append(active, f)
+		}
+	}
+	if len(active) == 0 {
+		b.WriteString("- none\n")
+	}
+	if !appendFindingList(b, active) {
+		return
+	}
+	if len(waived) > 0 {
+		b.WriteString(fmt.Sprintf("\n<details><summary>Waived (%d findings)</summary>\n\n", len(waived)))
+		appendFindingList(b, waived)
+		b.WriteString("\n</details>\n")
+	}
+}
+
+// appendFindingList writes findings with the waiver of waived ones and
+// reports whether all fit the comment size limit.
+func appendFindingList(b *strings.Builder, findings []policy.Finding) bool {
+	for _, f := range findings {
+		line := fmt.Sprintf("- `%s` `%s` %s (%s)", f.Severity, f.RuleID, f.Message, f.ResourceID)
+		if w := f.Waiver; w != nil {
+			line += fmt.Sprintf(" waived by %s: %s", w.Source, w.Reason)
+			if w.Expires != "" {
+				line += fmt.Sprintf(" (until %s)", w.Expires)
+			}
 		}
+		if b.Len()+len(line)+1 > maxCommentChars {
+			b.WriteString("- ... truncated (comment size limit)\n")
+			return false
+		}
+		b.WriteString(line + "\n")
+	}
+	return true
+}
+
+// appendChangeList writes changes grouped by parent, with their details, and
+// returns how many were written before hitting the limits.
+func appendChangeList(b *strings.Builder, changes []diff.Change, maxResourceDetails int) int {
+	printed := 0
+	sizeTruncated := false
+	group := ""
+	for _, c := range groupByParent(changes) {
 		if printed >= maxResourceDetails {
-			b.WriteString(fmt.Sprintf("- ... truncated (%d additional resources)\n", nonNoopTotal-printed))
+			b.WriteString(fmt.Sprintf("- ... truncated (%d additional resources)\n", len(changes)-printed))
 			break
 		}
 		line := fmt.Sprintf("- `%s` %s", c.Action, c.ID)
+		if c.PreviousID != "" {
+			line += fmt.Sprintf(" from=%s", c.PreviousID)
+		}
 		if len(c.ChangedKeys) > 0 {
 			line += fmt.Sprintf(" changed=%v", c.ChangedKeys)
 		}
 		if len(c.ChangedPaths) > 0 {
 			line += fmt.Sprintf(" paths=%v", c.ChangedPaths)
 		}
+		if c.Origin == diff.OriginBoth {
+			line += fmt.Sprintf(" drift=%v", diff.PathsByOrigin(c, diff.OriginDrift))
+		}
 		if len(c.Risks) > 0 {
 			line += fmt.Sprintf(" risks=%v", c.Risks)
 		}
+		if c.Parent != group {
+			line = fmt.Sprintf("\nRendered from `%s`:\n", c.Parent) + line
+			group = c.Parent
+		}
 		if b.Len()+len(line)+1 > maxCommentChars {
 			sizeTruncated = true
 			break
 		}
 		b.WriteString(line + "\n")
+		for _, reason := range c.Immutable {
+			b.WriteString(fmt.Sprintf("  - recreate required: %s\n", reason))
+		}
 		if details := renderChangeDetails(c); details != "" {
 			if b.Len()+len(details) > maxCommentChars {
 				sizeTruncated = true
@@ -146,29 +320,28 @@ func appendPlanSections(b *strings.Builder, changes []diff.Change, findings []po
 		printed++
 	}
 	if sizeTruncated {
-		b.WriteString(fmt.Sprintf("- ... truncated (%d additional resources; comment size limit)\n", nonNoopTotal-printed))
-	}
-	if printed == 0 {
-		b.WriteString("- none\n")
+		b.WriteString(fmt.Sprintf("- ... truncated (%d additional resources; comment size limit)\n", len(changes)-printed))
 	}
+	return printed
+}
 
-	b.WriteString("\n" + findingsHeading + "\n")
-	if len(findings) == 0 {
-		b.WriteString("- none\n")
-		return
-	}
-	for _, f := range findings {
-		line := fmt.Sprintf("- `%s` `%s` %s (%s)\n", f.Severity, f.RuleID, f.Message, f.ResourceID)
-		if b.Len()+len(line) > maxCommentChars {
-			b.WriteString("- ... truncated (comment size limit)\n")
-			return
-		}
-		b.WriteString(line)
-	}
+// groupByParent orders changes so resources expanded from the same Flux
+// HelmRelease or Kustomization are listed together, after the ones that have
+// no parent.
+func groupByParent(changes []diff.Change) []diff.Change {
+	out := append([]diff.Change{}, changes...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Parent < out[j].Parent
+	})
+	return out
 }
 
 func summaryLine(summary diff.Summary) string {
-	return fmt.Sprintf("Summary: CREATE=%d PATCH=%d DELETE=%d NO-OP=%d", summary.Creates, summary.Patches, summary.Deletes, summary.NoOps)
+	line := fmt.Sprintf("Summary: CREATE=%d PATCH=%d DELETE=%d NO-OP=%d", summary.Creates, summary.Patches, summary.Deletes, summary.NoOps)
+	if summary.Renames > 0 {
+		line += fmt.Sprintf(" RENAME/MOVE=%d", summary.Renames)
+	}
+	return line
 }
 
 func renderChangeDetails(c diff.Change) string {
@@ -183,7 +356,7 @@ func renderChangeDetails(c diff.Change) string {
 			return ""
 		}
 		return "\n```yaml\n# current\n" + truncateYAMLBlock(c.CurrentYAML) + "\n```\n"
-	case diff.Patch:
+	case diff.Patch, diff.Rename, diff.Move:
 		if len(c.AttributeDiff) > 0 {
 			return "\n```diff\n" + truncateDiffLines(c.AttributeDiff) + "\n```\n"
 		}
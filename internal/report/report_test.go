@@ -257,3 +257,133 @@ func TestBuildNoDiffComment(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildPlanCommentGroupsByParent(t *testing.T) {
+	changes := []diff.Change{
+		{ID: "a", Action: diff.Create, Parent: "ks|apps"},
+		{ID: "b", Action: diff.Create},
+		{ID: "c", Action: diff.Patch, Parent: "ks|apps"},
+	}
+	body := BuildPlanComment("p", "sha", changes, diff.Summary{Creates: 2, Patches: 1}, nil, 10)
+	if strings.Count(body, "Rendered from `ks|apps`") != 1 {
+		t.Fatalf("expected a single parent group, got %s", body)
+	}
+	b, group, a, c := strings.Index(body, " b\n"), strings.Index(body, "Rendered from"), strings.Index(body, " a\n"), strings.Index(body, " c\n")
+	if !(b < group && group < a && a < c) {
+		t.Fatalf("expected ungrouped changes first, then the group, got %s", body)
+	}
+}
+
+func TestBuildAggregatedPlanCommentLabelsGitOnlyProjects(t *testing.T) {
+	body := BuildAggregatedPlanComment("sha", []ProjectPlan{
+		{Project: "live", Changes: []diff.Change{{ID: "x", Action: diff.Create}}, Summary: diff.Summary{Creates: 1}},
+		{Project: "offline", Changes: []diff.Change{{ID: "y", Action: diff.Patch}}, Summary: diff.Summary{Patches: 1}, GitOnly: true},
+	}, 10)
+	if strings.Count(body, "Repository-only diff") != 1 {
+		t.Fatalf("expected one repository-only label, got: %s", body)
+	}
+	if strings.Index(body, "Repository-only diff") < strings.Index(body, "### Project: `offline`") {
+		t.Fatalf("expected label under the git-only project, got: %s", body)
+	}
+}
+
+func TestBuildPlanCommentSeparatesDrift(t *testing.T) {
+	changes := []diff.Change{
+		{ID: "mr", Action: diff.Patch, Origin: diff.OriginMR},
+		{ID: "mixed", Action: diff.Patch, Origin: diff.OriginBoth, PathOrigins: map[string]diff.Origin{"data.a": diff.OriginMR, "data.b": diff.OriginDrift}},
+		{ID: "hand-edit", Action: diff.Patch, Origin: diff.OriginDrift},
+	}
+	body := BuildPlanComment("p", "sha", changes, diff.Summary{Patches: 3}, nil, 10)
+	drift := strings.Index(body, "<details><summary>Pre-existing drift (1 resources")
+	if drift < 0 || strings.Index(body, "hand-edit") < drift || strings.Index(body, " mr\n") > drift {
+		t.Fatalf("expected drift-only change in collapsed section, got: %s", body)
+	}
+	if !strings.Contains(body, "mixed drift=[data.b]") {
+		t.Fatalf("expected drift paths on mixed change, got: %s", body)
+	}
+
+	body = BuildPlanComment("p", "sha", changes[2:], diff.Summary{Patches: 1}, nil, 10)
+	if !strings.Contains(body, "### Changes\n- none") || !strings.Contains(body, "</details>") {
+		t.Fatalf("expected no MR changes and a drift section, got: %s", body)
+	}
+}
+
+func TestBuildPlanCommentExplainsImmutableChanges(t *testing.T) {
+	changes := []diff.Change{{ID: "web", Action: diff.Patch, Risks: []string{diff.RiskRecreateRequired}, Immutable: []string{"`spec.selector` is immutable"}}}
+	body := BuildPlanComment("p", "sha", changes, diff.Summary{Patches: 1}, nil, 10)
+	if !strings.Contains(body, "risks=[recreate-required]\n  - recreate required: `spec.selector` is immutable\n") {
+		t.Fatalf("expected immutable explanation under the change, got: %s", body)
+	}
+}
+
+func TestBuildPlanCommentShowsRenames(t *testing.T) {
+	changes := []diff.Change{{ID: "v1|Service|n|web-v2", PreviousID: "v1|Service|n|web", Action: diff.Rename, Risks: []string{"service-endpoint-change"}, AttributeDiff: []string{"~ metadata.name: \"web\" -> \"web-v2\""}}}
+	body := BuildPlanComment("p", "sha", changes, diff.Summary{Renames: 1}, nil, 10)
+	for _, want := range []string{"RENAME/MOVE=1", "- `RENAME` v1|Service|n|web-v2 from=v1|Service|n|web", "```diff"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in body: %s", want, body)
+		}
+	}
+	if !hasActionableChanges(ProjectPlan{Summary: diff.Summary{Renames: 1}}) {
+		t.Fatal("expected renames to be actionable")
+	}
+}
+
+func TestBuildAggregatedPlanCommentNotesSuppressedFields(t *testing.T) {
+	body := BuildAggregatedPlanComment("sha", []ProjectPlan{{
+		Project:    "web",
+		Changes:    []diff.Change{{ID: "a", Action: diff.Patch}},
+		Summary:    diff.Summary{Patches: 1},
+		Suppressed: []string{"`spec.replicas` of apps/v1|Deployment|n|web (scaled by HorizontalPodAutoscaler `n/web`)"},
+	}}, 10)
+	if !strings.Contains(body, "> Not diffed (managed by autoscalers):\n> - `spec.replicas` of apps/v1|Deployment|n|web") {
+		t.Fatalf("expected suppression note, got: %s", body)
+	}
+}
+
+func TestBuildAggregatedPlanCommentSummarizesImages(t *testing.T) {
+	changes := []diff.Change{
+		{ID: "apps/v1|Deployment|n|web", Action: diff.Patch, Images: []diff.ImageChange{{Container: "web", Old: diff.ParseImageRef("app:1"), New: diff.ParseImageRef("app:2")}}},
+		{ID: "apps/v1|Deployment|n|drifted", Action: diff.Patch, Origin: diff.OriginDrift, Images: []diff.ImageChange{{Container: "x", New: diff.ParseImageRef("x:1")}}},
+		{ID: "apps/v1|Deployment|n|worker", Action: diff.Create, Images: []diff.ImageChange{{Container: "worker", New: diff.ParseImageRef("worker:1")}}},
+	}
+	body := BuildAggregatedPlanComment("sha", []ProjectPlan{{Project: "p", Changes: changes, Summary: diff.Summary{Creates: 1, Patches: 2}}}, 10)
+	table := strings.Index(body, "| Resource | Container | Old | New |\n|---|---|---|---|\n| `apps/v1\\|Deployment\\|n\\|web` | `web` | `app:1` | `app:2` |\n| `apps/v1\\|Deployment\\|n\\|worker` | `worker` | - | `worker:1` |\n")
+	if table < 0 || table > strings.Index(body, "#### Changes") {
+		t.Fatalf("expected image table above the changes, got: %s", body)
+	}
+	if strings.Contains(body, "`x:1`") {
+		t.Fatalf("expected drift images to stay out of the table, got: %s", body)
+	}
+}
+
+func TestBuildAggregatedPlanCommentShowsRolloutImpact(t *testing.T) {
+	body := BuildAggregatedPlanComment("sha", []ProjectPlan{{
+		Project: "p",
+		Changes: []diff.Change{{ID: "web", Action: diff.Patch}},
+		Summary: diff.Summary{Patches: 1},
+		Rollouts: []RolloutImpact{
+			{Workload: "apps/v1|Deployment|n|web", Restart: true, Pods: 4, Reasons: []string{"pod template changed"}},
+			{Workload: "apps/v1|DaemonSet|n|agent", Restart: true, Pods: -1, Reasons: []string{"pod template changed"}},
+			{Workload: "apps/v1|Deployment|n|api", Pods: 2, Reasons: []string{"references changed ConfigMap `c` via env"}},
+		},
+	}}, 10)
+	for _, want := range []string{"#### Rollout impact\n2 workloads restart (~4 pods)\n", "- `apps/v1|Deployment|n|web` restarts 4 pods: pod template changed", "- `apps/v1|DaemonSet|n|agent` restarts: pod", "- `apps/v1|Deployment|n|api` not restarted: references"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in body: %s", want, body)
+		}
+	}
+}
+
+func TestBuildPlanCommentListsWaivedFindings(t *testing.T) {
+	findings := []policy.Finding{
+		{ResourceID: "x", RuleID: "host-path-volume", Severity: policy.SeverityError, Message: "volume `data` mounts a hostPath", Waiver: &policy.Waiver{Source: "annotation", Reason: "node agent", Expires: "2026-12-31"}},
+	}
+	body := BuildPlanComment("p", "sha", []diff.Change{{ID: "x", Action: diff.Create}}, diff.Summary{Creates: 1}, findings, 10)
+	if !strings.Contains(body, "### Policy Findings\n- none\n") {
+		t.Fatalf("waived findings should not be listed as active: %s", body)
+	}
+	if !strings.Contains(body, "<details><summary>Waived (1 findings)</summary>") || !strings.Contains(body, "`host-path-volume` volume `data` mounts a hostPath (x) waived by annotation: node agent (until 2026-12-31)") {
+		t.Fatalf("expected waived section: %s", body)
+	}
+}
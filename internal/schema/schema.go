@@ -0,0 +1,210 @@
+// Package schema validates rendered resources offline against Kubernetes
+// OpenAPI definitions and CustomResourceDefinition schemas.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kustomize/kyaml/openapi/kubernetesapi"
+
+	"github.com/example/thule/internal/policy"
+	"github.com/example/thule/internal/render"
+)
+
+const gvkExtension = "x-kubernetes-group-version-kind"
+
+// Validator holds resource schemas indexed by group/version/kind.
+type Validator struct {
+	// Version is the Kubernetes version the built-in schemas come from.
+	Version string
+	// Outdated is set when the built-in schemas may be older than the
+	// cluster: the requested Kubernetes version is newer, or unknown. Fields
+	// they do not know may then be valid.
+	Outdated bool
+	// Requested is the Kubernetes version the schemas were selected for.
+	Requested   string
+	definitions spec.Definitions
+	byGVK       map[string]*spec.Schema
+	// crds are the GVKs whose schemas come from rendered CRDs, which are
+	// authoritative regardless of Outdated.
+	crds map[string]struct{}
+}
+
+var (
+	bundledMu sync.Mutex
+	bundled   = map[string]*Validator{}
+)
+
+// Bundled returns the validator for the bundled Kubernetes schemas closest
+// to (and not newer than) version, e.g. "1.29" or "v1.29.3". The oldest
+// bundled version is used when none qualifies; an empty version selects the
+// default. Bundled schemas ship with kustomize, which only carries a few
+// (currently v1.21.2), and are parsed once. When version is unknown or newer
+// than the selected schemas, Outdated is set and unknown fields are reported
+// as warnings, since they may have been added since.
+func Bundled(version string) (*Validator, error) {
+	selected := selectBundledVersion(version)
+	v, err := bundledValidator(selected)
+	if err != nil {
+		return nil, err
+	}
+	out := *v
+	out.Requested = version
+	_, known := policy.ParseKubeVersion(version)
+	out.Outdated = !known || (!sameMinor(version, selected) && versionLess(selected, version))
+	return &out, nil
+}
+
+func bundledValidator(selected string) (*Validator, error) {
+	bundledMu.Lock()
+	defer bundledMu.Unlock()
+	if v, ok := bundled[selected]; ok {
+		return v, nil
+	}
+	asset := filepath.Join("kubernetesapi", strings.ReplaceAll(selected, ".", "_"), "swagger.pb")
+	doc := &openapi_v2.Document{}
+	if err := proto.Unmarshal(kubernetesapi.OpenAPIMustAsset[selected](asset), doc); err != nil {
+		return nil, fmt.Errorf("parse bundled openapi %s: %w", selected, err)
+	}
+	v, err := FromDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	v.Version = selected
+	bundled[selected] = v
+	return v, nil
+}
+
+func selectBundledVersion(version string) string {
+	versions := make([]string, 0, len(kubernetesapi.OpenAPIMustAsset))
+	for v := range kubernetesapi.OpenAPIMustAsset {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	if version == "" {
+		return kubernetesapi.DefaultOpenAPI
+	}
+	selected := versions[0]
+	for _, v := range versions {
+		if !versionLess(version, v) {
+			selected = v
+		}
+	}
+	return selected
+}
+
+// versionLess compares "v1.21.2"-style versions by major, minor and patch;
+// versions that do not parse count as zero.
+func versionLess(a, b string) bool {
+	pa, _ := policy.ParseKubeVersion(a)
+	pb, _ := policy.ParseKubeVersion(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] < pb[i]
+		}
+	}
+	return false
+}
+
+// sameMinor reports whether two versions share major and minor.
+func sameMinor(a, b string) bool {
+	pa, _ := policy.ParseKubeVersion(a)
+	pb, _ := policy.ParseKubeVersion(b)
+	return pa[0] == pb[0] && pa[1] == pb[1]
+}
+
+// FromDocument builds a validator from an OpenAPI v2 document, such as the
+// one a live API server serves, which includes its installed CRDs.
+func FromDocument(doc *openapi_v2.Document) (*Validator, error) {
+	var swagger spec.Swagger
+	if _, err := swagger.FromGnostic(doc); err != nil {
+		return nil, fmt.Errorf("convert openapi document: %w", err)
+	}
+	v := &Validator{definitions: swagger.Definitions, byGVK: map[string]*spec.Schema{}}
+	if doc.GetInfo() != nil {
+		v.Version = doc.GetInfo().GetVersion()
+	}
+	for name := range swagger.Definitions {
+		def := swagger.Definitions[name]
+		raw, ok := def.Extensions[gvkExtension]
+		if !ok {
+			continue
+		}
+		b, _ := json.Marshal(raw)
+		var gvks []struct{ Group, Version, Kind string }
+		if json.Unmarshal(b, &gvks) != nil {
+			continue
+		}
+		for _, gvk := range gvks {
+			v.byGVK[gvkKey(gvk.Group, gvk.Version, gvk.Kind)] = &def
+		}
+	}
+	return v, nil
+}
+
+// WithCRDs returns a copy of v that also knows the served versions of the
+// CustomResourceDefinitions among resources.
+func (v *Validator) WithCRDs(resources []render.Resource) *Validator {
+	out := &Validator{Version: v.Version, Outdated: v.Outdated, Requested: v.Requested, definitions: v.definitions, byGVK: make(map[string]*spec.Schema, len(v.byGVK)), crds: map[string]struct{}{}}
+	for k, s := range v.byGVK {
+		out.byGVK[k] = s
+	}
+	for k := range v.crds {
+		out.crds[k] = struct{}{}
+	}
+	for _, r := range resources {
+		if r.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		crdSpec, _ := r.Body["spec"].(map[string]any)
+		group, _ := crdSpec["group"].(string)
+		names, _ := crdSpec["names"].(map[string]any)
+		kind, _ := names["kind"].(string)
+		versions, _ := crdSpec["versions"].([]any)
+		for _, item := range versions {
+			version, _ := item.(map[string]any)
+			name, _ := version["name"].(string)
+			raw, ok := lookup(version, "schema", "openAPIV3Schema")
+			if !ok || name == "" || kind == "" {
+				continue
+			}
+			b, err := json.Marshal(raw)
+			if err != nil {
+				continue
+			}
+			s := &spec.Schema{}
+			if json.Unmarshal(b, s) != nil {
+				continue
+			}
+			out.byGVK[gvkKey(group, name, kind)] = s
+			out.crds[gvkKey(group, name, kind)] = struct{}{}
+		}
+	}
+	return out
+}
+
+func gvkKey(group, version, kind string) string {
+	return group + "/" + version + "/" + kind
+}
+
+func lookup(m map[string]any, keys ...string) (any, bool) {
+	var cur any = m
+	for _, k := range keys {
+		next, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = next[k]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/example/thule/internal/render"
+)
+
+func TestSelectBundledVersion(t *testing.T) {
+	for _, version := range []string{"", "v1.29.3", "1.21", "1.10"} {
+		if got := selectBundledVersion(version); got != "v1.21.2" {
+			t.Fatalf("selectBundledVersion(%q) = %q", version, got)
+		}
+	}
+	if !versionLess("v1.9.0", "1.21") || versionLess("1.27+", "v1.27.0") {
+		t.Fatal("unexpected version ordering")
+	}
+}
+
+func TestValidateBuiltinKinds(t *testing.T) {
+	v, err := Bundled("1.21")
+	if err != nil {
+		t.Fatalf("bundled: %v", err)
+	}
+	deploy := render.Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "n", Name: "web", Body: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "n", "labels": map[string]any{"app": "web"}},
+		"spec": map[string]any{
+			"replicas": 2,
+			"selector": map[string]any{"matchLabels": map[string]any{"app": "web"}},
+			"strategy": map[string]any{"rollingUpdate": map[string]any{"maxSurge": "25%", "maxUnavailable": 0}},
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": map[string]any{"app": "web"}},
+				"spec": map[string]any{"containers": []any{
+					map[string]any{
+						"name":            "web",
+						"image":           "web:1",
+						"imagePullPolicy": "Allways",
+						"ports":           []any{map[string]any{"containerPort": "http"}},
+						"resources":       map[string]any{"limits": map[string]any{"cpu": 1, "memory": "1Gi"}},
+						"livenessProbe":   map[string]any{"httpGet": map[string]any{"port": "http", "path": "/"}},
+					},
+					map[string]any{"image": "sidecar:1", "imagePullPolicy": "IfNotPresent", "restartPolicyy": "Always"},
+				}},
+			},
+		},
+	}}
+	findings := v.Validate([]render.Resource{deploy})
+	got := []string{}
+	for _, f := range findings {
+		if f.RuleID != "schema-validation" || f.Severity != "ERROR" || f.ResourceID != deploy.ID() {
+			t.Fatalf("unexpected finding %+v", f)
+		}
+		got = append(got, f.Message)
+	}
+	want := []string{
+		"`spec.template.spec.containers[0].imagePullPolicy`: unsupported value \"Allways\" (expected one of Always, IfNotPresent, Never)",
+		"`spec.template.spec.containers[0].ports[0].containerPort`: expected an integer, got a string",
+		"`spec.template.spec.containers[1].name`: required field is missing",
+		"`spec.template.spec.containers[1].restartPolicyy`: unknown field",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+
+	newer, err := Bundled("v1.29.3")
+	if err != nil {
+		t.Fatalf("bundled: %v", err)
+	}
+	if unset, _ := Bundled(""); !newer.Outdated || !unset.Outdated || v.Outdated {
+		t.Fatalf("unexpected outdated flags %v %v %v", newer.Outdated, unset.Outdated, v.Outdated)
+	}
+	noted := false
+	for _, f := range newer.Validate([]render.Resource{deploy}) {
+		if f.RuleID == "schema-version-unavailable" {
+			noted = f.Severity == "WARN" && f.ResourceID == ""
+			continue
+		}
+		unknownField := strings.Contains(f.Message, "restartPolicyy")
+		if unknownField != (f.Severity == "WARN") {
+			t.Fatalf("only unknown fields should be warnings against outdated schemas: %+v", f)
+		}
+		if unknownField && !strings.HasSuffix(f.Message, "unknown field (not in the bundled v1.21.2 schema; it may be valid in v1.29.3)") {
+			t.Fatalf("unexpected message %q", f.Message)
+		}
+	}
+	if !noted {
+		t.Fatalf("expected a warning that v1.29.3 has no bundled schemas")
+	}
+	for _, f := range v.Validate([]render.Resource{deploy}) {
+		if f.RuleID == "schema-version-unavailable" {
+			t.Fatalf("expected no version warning when the bundled schemas match: %+v", f)
+		}
+	}
+
+	unknown := render.Resource{APIVersion: "example.com/v1", Kind: "Widget", Name: "w", Body: map[string]any{"spec": map[string]any{"anything": true}}}
+	if findings := v.Validate([]render.Resource{unknown}); len(findings) != 0 {
+		t.Fatalf("expected kinds without a schema to be skipped, got %+v", findings)
+	}
+}
+
+func TestValidateRepoCRDs(t *testing.T) {
+	// CRD schemas from the render stay authoritative with outdated bundled
+	// schemas.
+	v, err := Bundled("v1.30.0")
+	if err != nil {
+		t.Fatalf("bundled: %v", err)
+	}
+	crd := render.Resource{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: "widgets.example.com", Body: map[string]any{
+		"spec": map[string]any{
+			"group": "example.com",
+			"scope": "Namespaced",
+			"names": map[string]any{"kind": "Widget", "plural": "widgets"},
+			"versions": []any{map[string]any{
+				"name":    "v1",
+				"served":  true,
+				"storage": true,
+				"schema": map[string]any{"openAPIV3Schema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"spec": map[string]any{
+							"type":     "object",
+							"required": []any{"size"},
+							"properties": map[string]any{
+								"size":   map[string]any{"type": "string", "enum": []any{"small", "large"}},
+								"count":  map[string]any{"type": "integer"},
+								"extras": map[string]any{"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+							},
+						},
+					},
+				}},
+			}},
+		},
+	}}
+	valid := render.Resource{APIVersion: "example.com/v1", Kind: "Widget", Namespace: "n", Name: "ok", Body: map[string]any{
+		"apiVersion": "example.com/v1", "kind": "Widget", "metadata": map[string]any{"name": "ok"},
+		"spec": map[string]any{"size": "small", "count": 3, "extras": map[string]any{"free": "form"}},
+	}}
+	invalid := render.Resource{APIVersion: "example.com/v1", Kind: "Widget", Namespace: "n", Name: "bad", Body: map[string]any{
+		"apiVersion": "example.com/v1", "kind": "Widget", "metadata": map[string]any{"name": "bad"},
+		"spec": map[string]any{"size": "medium", "count": 1.5, "colour": "red"},
+	}}
+	findings := v.WithCRDs([]render.Resource{crd}).Validate([]render.Resource{crd, valid, invalid})
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, f.ResourceID+" "+f.Message)
+	}
+	want := []string{
+		"example.com/v1|Widget|n|bad `spec.colour`: unknown field",
+		"example.com/v1|Widget|n|bad `spec.count`: expected an integer, got a number",
+		"example.com/v1|Widget|n|bad `spec.size`: unsupported value \"medium\" (expected one of small, large)",
+		" no schemas are bundled for Kubernetes v1.30.0; built-in kinds were validated against the bundled v1.21.2 schemas",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+	if findings := v.Validate([]render.Resource{invalid}); len(findings) != 0 {
+		t.Fatalf("expected the CRD schema to be scoped to WithCRDs, got %+v", findings)
+	}
+}
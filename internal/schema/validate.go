@@ -0,0 +1,305 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+
+	"github.com/example/thule/internal/policy"
+	"github.com/example/thule/internal/render"
+)
+
+const (
+	quantityDefinition   = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+	maxErrorsPerResource = 20
+)
+
+// knownEnums adds the allowed values of common core fields; older OpenAPI
+// documents only list them in descriptions.
+var knownEnums = map[string][]string{
+	"io.k8s.api.core.v1.Container.imagePullPolicy":            {"Always", "IfNotPresent", "Never"},
+	"io.k8s.api.core.v1.EphemeralContainer.imagePullPolicy":   {"Always", "IfNotPresent", "Never"},
+	"io.k8s.api.core.v1.Container.terminationMessagePolicy":   {"File", "FallbackToLogsOnError"},
+	"io.k8s.api.core.v1.PodSpec.restartPolicy":                {"Always", "OnFailure", "Never"},
+	"io.k8s.api.core.v1.PodSpec.dnsPolicy":                    {"ClusterFirst", "ClusterFirstWithHostNet", "Default", "None"},
+	"io.k8s.api.core.v1.ContainerPort.protocol":               {"TCP", "UDP", "SCTP"},
+	"io.k8s.api.core.v1.ServicePort.protocol":                 {"TCP", "UDP", "SCTP"},
+	"io.k8s.api.core.v1.ServiceSpec.type":                     {"ClusterIP", "NodePort", "LoadBalancer", "ExternalName"},
+	"io.k8s.api.core.v1.ServiceSpec.externalTrafficPolicy":    {"Cluster", "Local"},
+	"io.k8s.api.core.v1.ServiceSpec.sessionAffinity":          {"ClientIP", "None"},
+	"io.k8s.api.apps.v1.DeploymentStrategy.type":              {"Recreate", "RollingUpdate"},
+	"io.k8s.api.apps.v1.StatefulSetUpdateStrategy.type":       {"OnDelete", "RollingUpdate"},
+	"io.k8s.api.apps.v1.DaemonSetUpdateStrategy.type":         {"OnDelete", "RollingUpdate"},
+	"io.k8s.api.apps.v1.StatefulSetSpec.podManagementPolicy":  {"OrderedReady", "Parallel"},
+	"io.k8s.api.batch.v1.JobSpec.completionMode":              {"NonIndexed", "Indexed"},
+	"io.k8s.api.batch.v1beta1.CronJobSpec.concurrencyPolicy":  {"Allow", "Forbid", "Replace"},
+	"io.k8s.api.batch.v1.CronJobSpec.concurrencyPolicy":       {"Allow", "Forbid", "Replace"},
+	"io.k8s.api.core.v1.PersistentVolumeClaimSpec.volumeMode": {"Block", "Filesystem"},
+	"io.k8s.api.networking.v1.HTTPIngressPath.pathType":       {"Exact", "Prefix", "ImplementationSpecific"},
+}
+
+// rootFields are accepted on every resource, including CRDs whose schema
+// does not declare them.
+var rootFields = map[string]struct{}{"apiVersion": {}, "kind": {}, "metadata": {}, "status": {}}
+
+// Validate checks resources against their schemas and reports violations as
+// ERROR findings naming the offending path. Unknown fields of built-in kinds
+// are WARN findings when the schemas are Outdated. Resources without a known
+// schema are skipped. When built-in kinds are validated against bundled
+// schemas of another minor version than Requested, a schema-version-unavailable
+// WARN finding says so.
+func (v *Validator) Validate(resources []render.Resource) []policy.Finding {
+	findings := []policy.Finding{}
+	builtin := false
+	for _, r := range resources {
+		group, version := "", r.APIVersion
+		if i := strings.Index(r.APIVersion, "/"); i >= 0 {
+			group, version = r.APIVersion[:i], r.APIVersion[i+1:]
+		}
+		key := gvkKey(group, version, r.Kind)
+		s, ok := v.byGVK[key]
+		if !ok {
+			continue
+		}
+		w := &walker{v: v}
+		_, crd := v.crds[key]
+		builtin = builtin || !crd
+		if v.Outdated && !crd {
+			newer := v.Requested
+			if newer == "" {
+				newer = "newer Kubernetes versions"
+			}
+			w.unknownNote = fmt.Sprintf(" (not in the bundled %s schema; it may be valid in %s)", v.Version, newer)
+		}
+		w.object("", "", r.Body, s, true)
+		sort.Slice(w.errs, func(i, j int) bool { return w.errs[i].msg < w.errs[j].msg })
+		for i, e := range w.errs {
+			if i == maxErrorsPerResource {
+				severity := policy.SeverityWarn
+				for _, rest := range w.errs[i:] {
+					if rest.severity == policy.SeverityError {
+						severity = policy.SeverityError
+					}
+				}
+				findings = append(findings, policy.Finding{ResourceID: r.ID(), RuleID: "schema-validation", Severity: severity, Message: fmt.Sprintf("... %d more schema errors", len(w.errs)-i)})
+				break
+			}
+			findings = append(findings, policy.Finding{ResourceID: r.ID(), RuleID: "schema-validation", Severity: e.severity, Message: e.msg})
+		}
+	}
+	if _, known := policy.ParseKubeVersion(v.Requested); builtin && known && !sameMinor(v.Requested, v.Version) {
+		findings = append(findings, policy.Finding{RuleID: "schema-version-unavailable", Severity: policy.SeverityWarn, Message: fmt.Sprintf("no schemas are bundled for Kubernetes %s; built-in kinds were validated against the bundled %s schemas", v.Requested, v.Version)})
+	}
+	return findings
+}
+
+type schemaError struct {
+	msg      string
+	severity policy.Severity
+}
+
+type walker struct {
+	v    *Validator
+	errs []schemaError
+	// unknownNote is appended to unknown field errors, which are warnings
+	// when set.
+	unknownNote string
+}
+
+func (w *walker) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "."
+	}
+	w.errs = append(w.errs, schemaError{msg: "`" + path + "`: " + fmt.Sprintf(format, args...), severity: policy.SeverityError})
+}
+
+func (w *walker) unknown(path string) {
+	if w.unknownNote == "" {
+		w.fail(path, "unknown field")
+		return
+	}
+	w.errs = append(w.errs, schemaError{msg: "`" + path + "`: unknown field" + w.unknownNote, severity: policy.SeverityWarn})
+}
+
+// resolve follows $ref to a definition and returns it with its name.
+func (w *walker) resolve(s *spec.Schema, name string) (*spec.Schema, string) {
+	for depth := 0; s != nil && s.Ref.String() != "" && depth < 10; depth++ {
+		name = strings.TrimPrefix(s.Ref.String(), "#/definitions/")
+		def, ok := w.v.definitions[name]
+		if !ok {
+			return nil, ""
+		}
+		s = &def
+	}
+	if s != nil && len(s.AllOf) == 1 && len(s.Properties) == 0 && s.Type == nil {
+		return w.resolve(&s.AllOf[0], name)
+	}
+	return s, name
+}
+
+func (w *walker) value(path, name string, val any, s *spec.Schema) {
+	s, name = w.resolve(s, name)
+	if s == nil || val == nil {
+		return
+	}
+	if name == quantityDefinition || s.Format == "int-or-string" || extensionTrue(s, "x-kubernetes-int-or-string") {
+		switch val.(type) {
+		case string, int, int64, float64:
+		default:
+			w.fail(path, "expected a string or number, got %s", typeName(val))
+		}
+		return
+	}
+	switch {
+	case s.Type.Contains("object") || len(s.Properties) > 0:
+		m, ok := val.(map[string]any)
+		if !ok {
+			w.fail(path, "expected an object, got %s", typeName(val))
+			return
+		}
+		w.object(path, name, m, s, false)
+	case s.Type.Contains("array"):
+		items, ok := val.([]any)
+		if !ok {
+			w.fail(path, "expected a list, got %s", typeName(val))
+			return
+		}
+		if s.Items == nil || s.Items.Schema == nil {
+			return
+		}
+		for i, item := range items {
+			w.value(fmt.Sprintf("%s[%d]", path, i), "", item, s.Items.Schema)
+		}
+	case s.Type.Contains("string"):
+		switch val.(type) {
+		case string:
+		case time.Time:
+			// YAML timestamps decode to time.Time.
+		default:
+			w.fail(path, "expected a string, got %s", typeName(val))
+			return
+		}
+		w.enum(path, val, s.Enum)
+	case s.Type.Contains("integer"):
+		if !isInteger(val) {
+			w.fail(path, "expected an integer, got %s", typeName(val))
+			return
+		}
+		w.enum(path, val, s.Enum)
+	case s.Type.Contains("number"):
+		switch val.(type) {
+		case int, int64, float64:
+		default:
+			w.fail(path, "expected a number, got %s", typeName(val))
+		}
+	case s.Type.Contains("boolean"):
+		if _, ok := val.(bool); !ok {
+			w.fail(path, "expected a boolean, got %s", typeName(val))
+		}
+	}
+}
+
+func (w *walker) object(path, name string, m map[string]any, s *spec.Schema, root bool) {
+	s, name = w.resolve(s, name)
+	if s == nil {
+		return
+	}
+	for _, req := range s.Required {
+		if _, ok := m[req]; !ok {
+			w.fail(join(path, req), "required field is missing")
+		}
+	}
+	preserve := extensionTrue(s, "x-kubernetes-preserve-unknown-fields") || extensionTrue(s, "x-kubernetes-embedded-resource")
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		next := join(path, k)
+		if prop, ok := s.Properties[k]; ok {
+			if enum, ok := knownEnums[name+"."+k]; ok {
+				w.enum(next, m[k], toAny(enum))
+			}
+			w.value(next, "", m[k], &prop)
+			continue
+		}
+		if root {
+			if _, ok := rootFields[k]; ok {
+				continue
+			}
+		}
+		switch {
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			w.value(next, "", m[k], s.AdditionalProperties.Schema)
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Allows:
+		case preserve || len(s.Properties) == 0:
+		default:
+			w.unknown(next)
+		}
+	}
+}
+
+func (w *walker) enum(path string, val any, allowed []any) {
+	if len(allowed) == 0 || val == nil {
+		return
+	}
+	names := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if fmt.Sprint(a) == fmt.Sprint(val) {
+			return
+		}
+		names = append(names, fmt.Sprint(a))
+	}
+	w.fail(path, "unsupported value %q (expected one of %s)", fmt.Sprint(val), strings.Join(names, ", "))
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func extensionTrue(s *spec.Schema, name string) bool {
+	v, ok := s.Extensions[name].(bool)
+	return ok && v
+}
+
+func isInteger(v any) bool {
+	switch n := v.(type) {
+	case int, int64:
+		return true
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int64, float64:
+		return "a number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toAny(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
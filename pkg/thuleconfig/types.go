@@ -2,14 +2,15 @@ package thuleconfig
 
 // Config defines project-level thule.conf settings.
 type Config struct {
-	Version    string  `json:"version"`
-	Project    string  `json:"project"`
-	ClusterRef string  `json:"clusterRef"`
-	Namespace  string  `json:"namespace"`
-	Render     Render  `json:"render"`
-	Diff       Diff    `json:"diff,omitempty"`
-	Policy     Policy  `json:"policy,omitempty"`
-	Comment    Comment `json:"comment,omitempty"`
+	Version     string  `json:"version"`
+	Project     string  `json:"project"`
+	ClusterRef  string  `json:"clusterRef"`
+	Namespace   string  `json:"namespace"`
+	KubeVersion string  `json:"kubeVersion,omitempty"`
+	Render      Render  `json:"render"`
+	Diff        Diff    `json:"diff,omitempty"`
+	Policy      Policy  `json:"policy,omitempty"`
+	Comment     Comment `json:"comment,omitempty"`
 }
 
 type Render struct {
@@ -22,6 +23,7 @@ type Render struct {
 type Helm struct {
 	ReleaseName string   `json:"releaseName,omitempty"`
 	ValuesFiles []string `json:"valuesFiles,omitempty"`
+	APIVersions []string `json:"apiVersions,omitempty"`
 }
 
 type Flux struct {
@@ -29,10 +31,26 @@ type Flux struct {
 }
 
 type Diff struct {
-	Prune        bool     `json:"prune"`
+	Prune bool `json:"prune"`
+	// IgnoreFields are "[[group/]Kind[/name]:]path" rules; paths accept
+	// "[*]" wildcards, list indexes and bracketed keys with dots.
 	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// Mode selects what the head render is compared against: "live" (the
+	// default) reads the cluster, "git-only" uses the merge base render.
+	Mode string `json:"mode,omitempty"`
+	// MergeKeys are "path=key" rules matching list elements of custom
+	// resources by key, e.g. "spec.listeners=name".
+	MergeKeys []string `json:"mergeKeys,omitempty"`
+	// FieldManagers restricts live comparison to fields owned by these
+	// managers (per managedFields) plus fields the manifests introduce.
+	FieldManagers []string `json:"fieldManagers,omitempty"`
 }
 
+const (
+	DiffModeLive    = "live"
+	DiffModeGitOnly = "git-only"
+)
+
 type Policy struct {
 	Profile string `json:"profile,omitempty"`
 }